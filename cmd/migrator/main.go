@@ -0,0 +1,379 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Command migrator applies and reverts the SQL migrations found in a
+// directory. It has to be built with the tag of the database driver to use,
+// for instance: go build -tags postgres ./cmd/migrator
+//
+// Usage:
+//
+//	migrator [flags] <command> [arguments]
+//
+// Exit codes:
+//
+//	0  the command ran and changed the database, or reported something.
+//	1  the command failed.
+//	2  the command line is invalid.
+//	3  there was nothing to do.
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/c4milo/migrator"
+)
+
+// Exit codes.
+const (
+	exitApplied = iota
+	exitFailed
+	exitUsage
+	exitNothingToDo
+)
+
+// errUsage is returned when a command is called with the wrong arguments.
+var errUsage = errors.New("invalid-usage")
+
+const usage = `Usage: migrator [flags] <command> [arguments]
+
+Commands:
+  init            creates the migrations table
+  validate        checks the migration files without running them
+  migrate         applies all pending migrations
+  migrate-to <id> applies or reverts migrations until <id> is the last applied
+  up <id>         applies a migration that was taken down
+  down <id>       takes down a migration
+  rollback [n]    reverts the last n migrations, 1 by default
+  rollback-to <id>
+                  reverts the migrations applied after <id>
+  reset           reverts every applied migration
+  redo [n]        reverts and applies again the last n migrations, 1 by default
+  status          lists migrations and whether they are applied
+  history [id]    lists every time migrations, or the given one, were applied or reverted
+  plan <command> [n]
+                  prints what migrate, rollback or redo would run
+
+Flags:
+`
+
+func main() {
+	flags := flag.NewFlagSet("migrator", flag.ContinueOnError)
+	dsn := flags.String("dsn", os.Getenv("MIGRATOR_DSN"), "database connection string, defaults to $MIGRATOR_DSN")
+	dir := flags.String("dir", "migrations", "directory containing the migration files")
+	dbType := flags.String("db", string(migrator.Postgres), "database type")
+	table := flags.String("table", migrator.DefaultTableName, "name of the table keeping track of migrations")
+	schema := flags.String("schema", "", "schema of the migrations table, defaults to the first one in the search path")
+	outOfOrder := flags.String("out-of-order", "error", "what to do with pending migrations older than the latest applied one: error, warn or allow")
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), usage)
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(os.Args[1:]); err != nil {
+		os.Exit(exitUsage)
+	}
+
+	policies := map[string]migrator.OutOfOrderPolicy{
+		"error": migrator.OutOfOrderError,
+		"warn":  migrator.OutOfOrderWarn,
+		"allow": migrator.OutOfOrderAllow,
+	}
+
+	policy, ok := policies[*outOfOrder]
+	if flags.NArg() == 0 || *dsn == "" || !ok {
+		flags.Usage()
+		os.Exit(exitUsage)
+	}
+
+	db, err := sql.Open(*dbType, *dsn)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "migrator: %v\n", err)
+		os.Exit(exitFailed)
+	}
+	defer db.Close()
+
+	m, err := migrator.NewMigratorFS(db, migrator.DBType(*dbType), os.DirFS(*dir), ".",
+		migrator.WithTableName(*table),
+		migrator.WithSchema(*schema),
+		migrator.WithOutOfOrder(policy),
+	)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "migrator: %v\n", err)
+		os.Exit(exitFailed)
+	}
+
+	code, err := run(m, flags.Arg(0), flags.Args()[1:])
+	if err == errUsage {
+		flags.Usage()
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "migrator: %v\n", err)
+	}
+	os.Exit(code)
+}
+
+// run executes cmd and returns the exit code to use.
+func run(m migrator.Migrator, cmd string, args []string) (int, error) {
+	switch cmd {
+	case "init":
+		// NewMigrator already initialized the migrations table.
+		return exitApplied, nil
+	case "validate":
+		// NewMigrator already rejected invalid migrations.
+		return exitApplied, nil
+	case "migrate":
+		return apply(m, migrator.CommandMigrate, nil, func(uint) error {
+			return m.Migrate()
+		})
+	case "rollback":
+		return apply(m, migrator.CommandRollback, args, func(n uint) error {
+			return m.Rollback(n)
+		})
+	case "rollback-to":
+		return rollbackTo(m, args)
+	case "reset":
+		return apply(m, migrator.CommandReset, nil, func(uint) error {
+			return m.Reset()
+		})
+	case "redo":
+		return apply(m, migrator.CommandRedo, args, func(n uint) error {
+			return m.Redo(n)
+		})
+	case "migrate-to":
+		return migrateTo(m, args)
+	case "up", "down":
+		return upDown(m, cmd, args)
+	case "status":
+		return status(m)
+	case "history":
+		return history(m, args)
+	case "plan":
+		if len(args) == 0 {
+			return exitUsage, errUsage
+		}
+
+		n, err := steps(args[1:])
+		if err != nil {
+			return exitUsage, err
+		}
+
+		plan, err := m.Plan(migrator.Command(args[0]), n)
+		if err != nil {
+			return exitFailed, err
+		}
+
+		if len(plan) == 0 {
+			fmt.Println("Nothing to do.")
+			return exitNothingToDo, nil
+		}
+
+		for _, s := range plan {
+			fmt.Printf("-- %s %s (%s)\n%s\n", s.Direction, s.ID, s.Filename, s.SQL)
+		}
+		return exitApplied, nil
+	}
+	return exitUsage, errUsage
+}
+
+// apply runs fn, a function applying or reverting migrations, only if cmd has
+// anything to do.
+func apply(m migrator.Migrator, cmd migrator.Command, args []string, fn func(n uint) error) (int, error) {
+	n, err := steps(args)
+	if err != nil {
+		return exitUsage, err
+	}
+
+	plan, err := m.Plan(cmd, n)
+	if err != nil {
+		return exitFailed, err
+	}
+
+	if len(plan) == 0 {
+		fmt.Println("Nothing to do.")
+		return exitNothingToDo, nil
+	}
+
+	if err := fn(n); err != nil {
+		return exitFailed, err
+	}
+
+	for _, s := range plan {
+		fmt.Printf("%-4s %s\n", s.Direction, s.Filename)
+	}
+	return exitApplied, nil
+}
+
+// migrateTo applies or reverts migrations until the ID given in args is the
+// last applied.
+func migrateTo(m migrator.Migrator, args []string) (int, error) {
+	if len(args) != 1 {
+		return exitUsage, errUsage
+	}
+
+	plan, err := m.PlanTo(args[0])
+	if err != nil {
+		return exitFailed, err
+	}
+
+	if len(plan) == 0 {
+		fmt.Println("Nothing to do.")
+		return exitNothingToDo, nil
+	}
+
+	if err := m.MigrateTo(args[0]); err != nil {
+		return exitFailed, err
+	}
+
+	for _, s := range plan {
+		fmt.Printf("%-4s %s\n", s.Direction, s.Filename)
+	}
+	return exitApplied, nil
+}
+
+// rollbackTo reverts the migrations applied after the ID given in args.
+func rollbackTo(m migrator.Migrator, args []string) (int, error) {
+	if len(args) != 1 {
+		return exitUsage, errUsage
+	}
+
+	plan, err := m.PlanRollbackTo(args[0])
+	if err != nil {
+		return exitFailed, err
+	}
+
+	if len(plan) == 0 {
+		fmt.Println("Nothing to do.")
+		return exitNothingToDo, nil
+	}
+
+	if err := m.RollbackTo(args[0]); err != nil {
+		return exitFailed, err
+	}
+
+	for _, s := range plan {
+		fmt.Printf("%-4s %s\n", s.Direction, s.Filename)
+	}
+	return exitApplied, nil
+}
+
+// upDown applies or takes down the migration ID given in args.
+func upDown(m migrator.Migrator, cmd string, args []string) (int, error) {
+	if len(args) != 1 {
+		return exitUsage, errUsage
+	}
+
+	id := args[0]
+	ms, err := m.Migrations(id)
+	if err != nil {
+		return exitFailed, err
+	}
+
+	if len(ms) == 0 {
+		return exitFailed, migrator.ErrMigrationNotFound
+	}
+
+	if ms[0].Status == cmd {
+		fmt.Println("Nothing to do.")
+		return exitNothingToDo, nil
+	}
+
+	if cmd == "up" {
+		err = m.Up(id)
+	} else {
+		err = m.Down(id)
+	}
+
+	if err != nil {
+		return exitFailed, err
+	}
+
+	fmt.Printf("%-4s %s\n", cmd, ms[0].Filename)
+	return exitApplied, nil
+}
+
+// status prints every known migration, oldest first, along with its status.
+func status(m migrator.Migrator) (int, error) {
+	applied, err := m.Migrations()
+	if err != nil {
+		return exitFailed, err
+	}
+
+	pending, err := m.Plan(migrator.CommandMigrate)
+	if err != nil {
+		return exitFailed, err
+	}
+
+	type row struct {
+		id, filename, status, updated string
+	}
+
+	rows := make(map[string]*row)
+	for _, a := range applied {
+		rows[a.ID] = &row{a.ID, a.Filename, a.Status, a.UpdatedAt.Format("2006-01-02 15:04:05")}
+	}
+
+	for _, p := range pending {
+		if _, ok := rows[p.ID]; !ok {
+			rows[p.ID] = &row{p.ID, p.Filename, "pending", ""}
+		}
+	}
+
+	ids := make([]string, 0, len(rows))
+	for id := range rows {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSTATUS\tUPDATED\tFILE")
+	for _, id := range ids {
+		r := rows[id]
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.id, r.status, r.updated, r.filename)
+	}
+	w.Flush()
+	return exitApplied, nil
+}
+
+// history prints every transition of the migration given in args, or of all of
+// them, oldest first.
+func history(m migrator.Migrator, args []string) (int, error) {
+	if len(args) > 1 {
+		return exitUsage, errUsage
+	}
+
+	entries, err := m.History(args...)
+	if err != nil {
+		return exitFailed, err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "AT\tID\tDIRECTION\tDURATION\tBY\tHOST\tVERSION")
+	for _, h := range entries {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", h.CreatedAt.Format("2006-01-02 15:04:05"),
+			h.ID, h.Direction, h.Duration, h.DBUser, h.Hostname, h.AppVersion)
+	}
+	w.Flush()
+	return exitApplied, nil
+}
+
+// steps parses the optional number of steps taken by rollback, redo and plan.
+func steps(args []string) (uint, error) {
+	switch len(args) {
+	case 0:
+		return 1, nil
+	case 1:
+		n, err := strconv.ParseUint(args[0], 10, 0)
+		if err != nil {
+			return 0, fmt.Errorf("invalid number of steps %q", args[0])
+		}
+		return uint(n), nil
+	}
+	return 0, errUsage
+}
@@ -0,0 +1,203 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"testing"
+	"testing/fstest"
+
+	"github.com/c4milo/migrator"
+	"github.com/hooklift/assert"
+)
+
+const fakeDB migrator.DBType = "fake"
+
+func init() {
+	migrator.Register(fakeDB, func(db *sql.DB, c migrator.Config) (migrator.Driver, error) {
+		return &fakeDriver{rows: make(map[string]migrator.Migration)}, nil
+	})
+}
+
+// fakeDriver keeps the migrations table in memory and fails migrations whose
+// SQL is "fail".
+type fakeDriver struct {
+	rows map[string]migrator.Migration
+}
+
+func (d *fakeDriver) Bootstrap(ctx context.Context) error {
+	return nil
+}
+
+func (d *fakeDriver) Lock(ctx context.Context) (func(), error) {
+	return func() {}, nil
+}
+
+func (d *fakeDriver) Begin(ctx context.Context, transactional bool) (migrator.Tx, error) {
+	return &fakeTx{driver: d}, nil
+}
+
+func (d *fakeDriver) List(ctx context.Context, ids ...string) ([]*migrator.Migration, error) {
+	var ms []*migrator.Migration
+	for id, row := range d.rows {
+		if len(ids) > 0 && id != ids[0] {
+			continue
+		}
+		m := row
+		ms = append(ms, &m)
+	}
+	sort.Slice(ms, func(i, j int) bool { return ms[i].ID > ms[j].ID })
+	return ms, nil
+}
+
+func (d *fakeDriver) History(ctx context.Context, ids ...string) ([]*migrator.HistoryEntry, error) {
+	return nil, nil
+}
+
+type fakeTx struct {
+	driver *fakeDriver
+	rows   []migrator.Migration
+}
+
+func (tx *fakeTx) Apply(ctx context.Context, query string) error {
+	if query == "fail" {
+		return errors.New("syntax error")
+	}
+	return nil
+}
+
+func (tx *fakeTx) ApplyFunc(ctx context.Context, fn migrator.MigrationFunc) error {
+	return fn(ctx, nil)
+}
+
+func (tx *fakeTx) Record(ctx context.Context, m *migrator.Migration, status migrator.Direction) error {
+	row := *m
+	row.Status = string(status)
+	tx.rows = append(tx.rows, row)
+	return nil
+}
+
+func (tx *fakeTx) Commit() error {
+	for _, row := range tx.rows {
+		tx.driver.rows[row.ID] = row
+	}
+	return nil
+}
+
+func (tx *fakeTx) Rollback() error {
+	return nil
+}
+
+func TestRun(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0001_one_up.sql":   {Data: []byte("up 1")},
+		"0001_one_down.sql": {Data: []byte("down 1")},
+		"0002_two_up.sql":   {Data: []byte("up 2")},
+		"0002_two_down.sql": {Data: []byte("down 2")},
+	}
+
+	m, err := migrator.NewMigratorFS(new(sql.DB), fakeDB, fsys, ".")
+	assert.Ok(t, err)
+
+	tests := []struct {
+		cmd  string
+		args []string
+		code int
+		err  error
+	}{
+		{"bogus", nil, exitUsage, errUsage},
+		{"plan", nil, exitUsage, errUsage},
+		{"rollback", []string{"1", "2"}, exitUsage, errUsage},
+		{"migrate-to", nil, exitUsage, errUsage},
+		{"rollback-to", nil, exitUsage, errUsage},
+		{"up", nil, exitUsage, errUsage},
+		{"history", []string{"0001", "0002"}, exitUsage, errUsage},
+		{"migrate", nil, exitApplied, nil},
+		{"migrate", nil, exitNothingToDo, nil},
+		{"plan", []string{"migrate"}, exitNothingToDo, nil},
+		{"plan", []string{"rollback", "2"}, exitApplied, nil},
+		{"up", []string{"0001"}, exitNothingToDo, nil},
+		{"up", []string{"0009"}, exitFailed, migrator.ErrMigrationNotFound},
+		{"rollback", nil, exitApplied, nil},
+		{"down", []string{"0002"}, exitNothingToDo, nil},
+		{"migrate-to", []string{"0002"}, exitApplied, nil},
+		{"rollback-to", []string{"0001"}, exitApplied, nil},
+		{"rollback-to", []string{"0001"}, exitNothingToDo, nil},
+		{"reset", nil, exitApplied, nil},
+		{"reset", nil, exitNothingToDo, nil},
+		{"status", nil, exitApplied, nil},
+		{"init", nil, exitApplied, nil},
+	}
+
+	for _, tt := range tests {
+		code, err := run(m, tt.cmd, tt.args)
+		assert.Equals(t, tt.code, code)
+		assert.Cond(t, errors.Is(err, tt.err), "%s %v: expected %v, got %v", tt.cmd, tt.args, tt.err, err)
+	}
+
+	code, err := run(m, "rollback", []string{"many"})
+	assert.Equals(t, exitUsage, code)
+	assert.Equals(t, `invalid number of steps "many"`, err.Error())
+
+	code, err = run(m, "plan", []string{"redo", "-1"})
+	assert.Equals(t, exitUsage, code)
+	assert.Equals(t, `invalid number of steps "-1"`, err.Error())
+
+	code, err = run(m, "migrate-to", []string{"0009"})
+	assert.Equals(t, exitFailed, code)
+	assert.Cond(t, errors.Is(err, migrator.ErrMigrationNotFound), "expected ErrMigrationNotFound, got %v", err)
+
+	code, err = run(m, "rollback-to", []string{"0009"})
+	assert.Equals(t, exitFailed, code)
+	assert.Cond(t, errors.Is(err, migrator.ErrMigrationNotFound), "expected ErrMigrationNotFound, got %v", err)
+}
+
+func TestRunFailure(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0001_one_up.sql":   {Data: []byte("up 1")},
+		"0001_one_down.sql": {Data: []byte("down 1")},
+		"0002_bad_up.sql":   {Data: []byte("fail")},
+		"0002_bad_down.sql": {Data: []byte("down 2")},
+	}
+
+	m, err := migrator.NewMigratorFS(new(sql.DB), fakeDB, fsys, ".")
+	assert.Ok(t, err)
+
+	code, err := run(m, "migrate", nil)
+	assert.Equals(t, exitFailed, code)
+	assert.Cond(t, errors.Is(err, migrator.ErrMigrationFailed), "expected ErrMigrationFailed, got %v", err)
+
+	code, err = run(m, "rollback", nil)
+	assert.Equals(t, exitApplied, code)
+	assert.Ok(t, err)
+}
+
+func TestSteps(t *testing.T) {
+	tests := []struct {
+		args []string
+		n    uint
+		err  string
+	}{
+		{nil, 1, ""},
+		{[]string{"3"}, 3, ""},
+		{[]string{"0"}, 0, ""},
+		{[]string{"-1"}, 0, `invalid number of steps "-1"`},
+		{[]string{"two"}, 0, `invalid number of steps "two"`},
+		{[]string{"1", "2"}, 0, "invalid-usage"},
+	}
+
+	for _, tt := range tests {
+		n, err := steps(tt.args)
+		assert.Equals(t, tt.n, n)
+		if tt.err == "" {
+			assert.Ok(t, err)
+		} else {
+			assert.Equals(t, tt.err, err.Error())
+		}
+	}
+}
@@ -0,0 +1,479 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package migrator
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Queryer runs statements on the connection, or the transaction, a migration
+// runs on. It is satisfied by *sql.Conn and *sql.Tx.
+type Queryer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Dialect holds what sets a SQL database apart for the driver returned by
+// NewSQLDriver, which does the rest.
+type Dialect interface {
+	// Quote returns name quoted as an identifier and qualified with schema if
+	// given.
+	Quote(schema, name string) string
+	// Placeholder returns the bind parameter of the nth argument of a query,
+	// counting from 1.
+	Placeholder(n int) string
+	// CreateTable returns the statement creating the migrations table, unless
+	// it exists, with the id, name, filename, up, down, status, checksum,
+	// out_of_order, sequence, duration_ms, db_user, hostname, app_version,
+	// created_at and updated_at columns. The status column is validated by
+	// the constraint statusCheck. Both names are already quoted.
+	CreateTable(table, statusCheck string) string
+	// CreateHistoryTable returns the statement creating the history table,
+	// unless it exists, with an auto incremented id column and the
+	// migration_id, name, filename, direction, checksum, out_of_order,
+	// duration_ms, db_user, hostname, app_version and created_at columns. The
+	// name is already quoted.
+	CreateHistoryTable(table string) string
+	// CurrentUser returns the SQL expression evaluating to the user of the
+	// connection, or NULL for databases without users.
+	CurrentUser() string
+	// AddColumn returns the statement adding column, one of those created by
+	// CreateTable since the migrations table was first released, to a table
+	// lacking it.
+	AddColumn(table, column string) string
+	// Upsert returns the statement recording a migration, which takes the id,
+	// name, filename, up, down, status, checksum, out_of_order, sequence,
+	// duration_ms, hostname and app_version, in that order, inserting it or
+	// updating the row with the same id. The db_user column is set to the
+	// user of the connection, if the database has users.
+	Upsert(table string) string
+	// TryLock returns the statement taking the advisory lock identified by the
+	// key given as argument without waiting, returning whether it was taken,
+	// and Unlock the one releasing it. Both are empty for databases without
+	// advisory locks, in which case migrations are only serialized within the
+	// process.
+	TryLock() string
+	Unlock() string
+	// Transactional reports whether schema changes can be rolled back, in
+	// which case a migration and its record run in a single transaction.
+	// Otherwise the migration runs straight away and is recorded afterwards.
+	Transactional() bool
+	// Session applies the statement and lock timeouts of c to the connection
+	// a migration runs on, before the transaction begins. It returns the
+	// statements restoring the connection settings once the migration ends.
+	// The connection is discarded if it fails.
+	Session(ctx context.Context, conn Queryer, c Config) (reset []string, err error)
+	// BoundsStatements reports whether Session makes the database abort any
+	// statement running longer than the statement timeout. Otherwise the SQL
+	// of a migration is bounded by it as a whole, through its context.
+	BoundsStatements() bool
+	// Check validates the changes made by a migration before they are
+	// committed.
+	Check(ctx context.Context, q Queryer) error
+}
+
+// sqlDriver implements Driver for databases reached through database/sql,
+// leaving what tells them apart to a Dialect.
+type sqlDriver struct {
+	db      *sql.DB
+	dialect Dialect
+	config  Config
+	// table is the quoted, and optionally schema qualified, name of the
+	// migrations table, history the one of the history table and statusCheck
+	// the quoted name of the constraint validating its status column.
+	table       string
+	history     string
+	statusCheck string
+}
+
+// NewSQLDriver creates a Driver for db, speaking the given dialect.
+func NewSQLDriver(db *sql.DB, dialect Dialect, c Config) Driver {
+	return newSQLDriver(db, dialect, c)
+}
+
+func newSQLDriver(db *sql.DB, dialect Dialect, c Config) *sqlDriver {
+	return &sqlDriver{
+		db:          db,
+		dialect:     dialect,
+		config:      c,
+		table:       dialect.Quote(c.Schema, c.TableName),
+		history:     dialect.Quote(c.Schema, c.TableName+"_history"),
+		statusCheck: dialect.Quote("", c.TableName+"_status_check"),
+	}
+}
+
+// addedColumns are the columns of the migrations table added since it was
+// first released, in the order they were added.
+var addedColumns = []string{"out_of_order", "sequence", "duration_ms", "db_user", "hostname", "app_version"}
+
+// Bootstrap creates the migrations and history tables and adds the columns
+// missing from tables created by previous versions of this package.
+func (d *sqlDriver) Bootstrap(ctx context.Context) error {
+	if _, err := d.db.ExecContext(ctx, d.dialect.CreateTable(d.table, d.statusCheck)); err != nil {
+		return err
+	}
+
+	if _, err := d.db.ExecContext(ctx, d.dialect.CreateHistoryTable(d.history)); err != nil {
+		return err
+	}
+
+	rows, err := d.db.QueryContext(ctx, fmt.Sprintf(`SELECT * FROM %s WHERE 1 = 0`, d.table))
+	if err != nil {
+		return err
+	}
+
+	columns, err := rows.Columns()
+	rows.Close()
+	if err != nil {
+		return err
+	}
+
+	existing := make(map[string]bool, len(columns))
+	for _, c := range columns {
+		existing[strings.ToLower(c)] = true
+	}
+
+	for _, c := range addedColumns {
+		if existing[c] {
+			continue
+		}
+
+		stmt := d.dialect.AddColumn(d.table, c)
+		d.config.Logger.Info("upgrading migrations table", "table", d.table, "statement", stmt)
+		if _, err := d.db.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Lock takes the advisory lock of the dialect, polling until it is released by
+// whoever holds it. It is held on a dedicated connection, so the pool must
+// allow at least two open connections, otherwise migrating would wait forever
+// for the one holding the lock.
+func (d *sqlDriver) Lock(ctx context.Context) (func(), error) {
+	if d.dialect.TryLock() == "" {
+		return func() {}, nil
+	}
+
+	if d.db.Stats().MaxOpenConnections == 1 {
+		return nil, fmt.Errorf("%w: the connection pool must allow at least two open connections", ErrLockFailed)
+	}
+
+	conn, err := d.db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrLockFailed, err)
+	}
+
+	key := d.config.LockKey
+	deadline := time.Now().Add(d.config.LockWait)
+	for {
+		// NULL stands for errors such as running out of memory.
+		var acquired sql.NullBool
+		if err := conn.QueryRowContext(ctx, d.dialect.TryLock(), key).Scan(&acquired); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("%w: %w", ErrLockFailed, err)
+		}
+
+		if !acquired.Valid {
+			conn.Close()
+			return nil, fmt.Errorf("%w: taking lock %d returned NULL", ErrLockFailed, key)
+		}
+
+		if acquired.Bool {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			conn.Close()
+			return nil, fmt.Errorf("%w: waited %s for lock %d", ErrLockTimeout, d.config.LockWait, key)
+		}
+
+		select {
+		case <-ctx.Done():
+			conn.Close()
+			return nil, ctx.Err()
+		case <-time.After(lockPollInterval):
+		}
+	}
+
+	return func() {
+		// The release must go through even if ctx was canceled while
+		// migrating, otherwise the lock would be held until the connection
+		// is recycled.
+		if _, err := conn.ExecContext(context.Background(), d.dialect.Unlock(), key); err != nil {
+			d.config.Logger.Error("releasing migration lock failed", "key", key, "error", err)
+		}
+		conn.Close()
+	}, nil
+}
+
+// Begin sets up a dedicated connection for a migration and, if both the
+// migration and the dialect are transactional, starts a transaction on it.
+func (d *sqlDriver) Begin(ctx context.Context, transactional bool) (Tx, error) {
+	conn, err := d.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	reset, err := d.dialect.Session(ctx, conn, d.config)
+	if err != nil {
+		// Some settings may have been changed already.
+		conn.Raw(func(any) error { return driver.ErrBadConn })
+		conn.Close()
+		return nil, err
+	}
+
+	t := &sqlTx{driver: d, conn: conn, reset: reset, q: conn}
+	if transactional && d.dialect.Transactional() {
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			t.release()
+			return nil, err
+		}
+		t.tx, t.q = tx, tx
+	}
+	return t, nil
+}
+
+// List returns the recorded migrations, or only those with the given IDs.
+func (d *sqlDriver) List(ctx context.Context, IDs ...string) ([]*Migration, error) {
+	query := fmt.Sprintf(`
+		SELECT id, name, filename, up, down, status, checksum, out_of_order, sequence,
+		       duration_ms, db_user, hostname, app_version, created_at, updated_at
+		FROM %s
+	`, d.table)
+
+	args := make([]any, len(IDs))
+	if len(IDs) > 0 {
+		params := make([]string, len(IDs))
+		for i, id := range IDs {
+			args[i] = id
+			params[i] = d.dialect.Placeholder(i + 1)
+		}
+		query += ` WHERE id IN (` + strings.Join(params, ", ") + `)`
+	}
+
+	query += ` ORDER BY id DESC`
+
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var migrations []*Migration
+	for rows.Next() {
+		// Columns added along the way are null for migrations recorded
+		// before.
+		var sum, status, user, host, version sql.NullString
+		var outOfOrder sql.NullBool
+		var seq, duration sql.NullInt64
+		m := new(Migration)
+		if err := rows.Scan(&m.ID, &m.Name, &m.Filename, &m.Up, &m.Down, &status, &sum, &outOfOrder,
+			&seq, &duration, &user, &host, &version, &m.CreatedAt, &m.UpdatedAt); err != nil {
+			return nil, err
+		}
+
+		m.Status = status.String
+		m.Checksum = sum.String
+		m.OutOfOrder = outOfOrder.Bool
+		m.Sequence = seq.Int64
+		m.Duration = time.Duration(duration.Int64) * time.Millisecond
+		m.DBUser, m.Hostname, m.AppVersion = user.String, host.String, version.String
+		migrations = append(migrations, m)
+	}
+	return migrations, rows.Err()
+}
+
+// History returns the transitions recorded in the history table, or only those
+// of the migrations with the given IDs, oldest first.
+func (d *sqlDriver) History(ctx context.Context, IDs ...string) ([]*HistoryEntry, error) {
+	query := fmt.Sprintf(`
+		SELECT migration_id, name, filename, direction, checksum, out_of_order,
+		       duration_ms, db_user, hostname, app_version, created_at
+		FROM %s
+	`, d.history)
+
+	args := make([]any, len(IDs))
+	if len(IDs) > 0 {
+		params := make([]string, len(IDs))
+		for i, id := range IDs {
+			args[i] = id
+			params[i] = d.dialect.Placeholder(i + 1)
+		}
+		query += ` WHERE migration_id IN (` + strings.Join(params, ", ") + `)`
+	}
+
+	query += ` ORDER BY id`
+
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []*HistoryEntry
+	for rows.Next() {
+		var sum, user, host, version sql.NullString
+		var duration sql.NullInt64
+		var direction string
+		h := new(HistoryEntry)
+		if err := rows.Scan(&h.ID, &h.Name, &h.Filename, &direction, &sum, &h.OutOfOrder,
+			&duration, &user, &host, &version, &h.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		h.Direction = Direction(direction)
+		h.Checksum = sum.String
+		h.Duration = time.Duration(duration.Int64) * time.Millisecond
+		h.DBUser, h.Hostname, h.AppVersion = user.String, host.String, version.String
+		history = append(history, h)
+	}
+	return history, rows.Err()
+}
+
+// sqlTx runs a migration on a dedicated connection, within a transaction if
+// the dialect is transactional.
+type sqlTx struct {
+	driver *sqlDriver
+	conn   *sql.Conn
+	tx     *sql.Tx
+	// q is tx, or conn when there is no transaction.
+	q     Queryer
+	reset []string
+}
+
+// Apply runs query, bounded by the statement timeout when the database can't
+// bound its statements, and checks the changes it made.
+func (t *sqlTx) Apply(ctx context.Context, query string) error {
+	ctx, cancel := t.bound(ctx)
+	defer cancel()
+
+	if _, err := t.q.ExecContext(ctx, query); err != nil {
+		return err
+	}
+	return t.driver.dialect.Check(ctx, t.q)
+}
+
+// ApplyFunc runs fn and checks the changes it made. Without a transaction, fn
+// gets one of its own, committed right away. Unlike Apply, fn isn't bounded by
+// the statement timeout as a whole, since it may run any number of statements.
+func (t *sqlTx) ApplyFunc(ctx context.Context, fn MigrationFunc) error {
+	if t.tx != nil {
+		if err := fn(ctx, t.tx); err != nil {
+			return err
+		}
+		return t.driver.dialect.Check(ctx, t.q)
+	}
+
+	tx, err := t.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(ctx, tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := t.driver.dialect.Check(ctx, tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// bound returns ctx bounded by the statement timeout, if any and the database
+// doesn't bound statements on its own.
+func (t *sqlTx) bound(ctx context.Context) (context.Context, context.CancelFunc) {
+	if timeout := t.driver.config.StatementTimeout; timeout > 0 && !t.driver.dialect.BoundsStatements() {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return ctx, func() {}
+}
+
+// Record inserts or updates m with the given status and appends the transition
+// to the history. Without a transaction, both get one of their own.
+func (t *sqlTx) Record(ctx context.Context, m *Migration, status Direction) error {
+	if t.tx != nil {
+		return t.record(ctx, t.tx, m, status)
+	}
+
+	tx, err := t.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := t.record(ctx, tx, m, status); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (t *sqlTx) record(ctx context.Context, q Queryer, m *Migration, status Direction) error {
+	d := t.driver
+	_, err := q.ExecContext(ctx, d.dialect.Upsert(d.table),
+		m.ID, m.Name, m.Filename, m.Up, m.Down, string(status), m.Checksum, m.OutOfOrder,
+		m.Sequence, m.Duration.Milliseconds(), m.Hostname, m.AppVersion)
+	if err != nil {
+		return err
+	}
+
+	params := make([]string, 9)
+	for i := range params {
+		params[i] = d.dialect.Placeholder(i + 1)
+	}
+
+	_, err = q.ExecContext(ctx, fmt.Sprintf(`
+		INSERT INTO %s (
+			migration_id, name, filename, direction, checksum, out_of_order,
+			duration_ms, hostname, app_version, db_user
+		) VALUES (%s, %s)
+	`, d.history, strings.Join(params, ", "), d.dialect.CurrentUser()),
+		m.ID, m.Name, m.Filename, string(status), m.Checksum, m.OutOfOrder,
+		m.Duration.Milliseconds(), m.Hostname, m.AppVersion)
+	return err
+}
+
+// Commit commits the transaction, if any, and releases the connection.
+func (t *sqlTx) Commit() error {
+	defer t.release()
+	if t.tx == nil {
+		return nil
+	}
+	return t.tx.Commit()
+}
+
+// Rollback rolls back the transaction, if any, and releases the connection.
+// Without a transaction, whatever the migration ran stays applied.
+func (t *sqlTx) Rollback() error {
+	defer t.release()
+	if t.tx == nil {
+		return nil
+	}
+	return t.tx.Rollback()
+}
+
+// release restores the connection settings changed by the dialect and hands
+// the connection back to the pool.
+func (t *sqlTx) release() {
+	for _, stmt := range t.reset {
+		if _, err := t.conn.ExecContext(context.Background(), stmt); err != nil {
+			t.driver.config.Logger.Warn("restoring connection settings failed", "error", err)
+			// Discards the connection rather than leaking the settings.
+			t.conn.Raw(func(any) error { return driver.ErrBadConn })
+			break
+		}
+	}
+	t.conn.Close()
+}
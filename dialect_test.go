@@ -0,0 +1,168 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package migrator
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/hooklift/assert"
+)
+
+// fakeDialect spells every statement as a short keyword, so the statements run
+// by the SQL driver can be told apart.
+type fakeDialect struct {
+	transactional bool
+}
+
+func (fakeDialect) Quote(schema, name string) string             { return "[" + name + "]" }
+func (fakeDialect) Placeholder(n int) string                     { return fmt.Sprintf("@p%d", n) }
+func (fakeDialect) CreateTable(table, statusCheck string) string { return "CREATE " + table }
+func (fakeDialect) CreateHistoryTable(table string) string       { return "CREATE " + table }
+func (fakeDialect) CurrentUser() string                          { return "USER" }
+func (fakeDialect) AddColumn(table, column string) string        { return "ADD " + column }
+func (fakeDialect) Upsert(table string) string                   { return "UPSERT " + table }
+func (fakeDialect) TryLock() string                              { return "LOCK" }
+func (fakeDialect) Unlock() string                               { return "UNLOCK" }
+func (d fakeDialect) Transactional() bool                        { return d.transactional }
+func (fakeDialect) BoundsStatements() bool                       { return true }
+
+func (fakeDialect) Session(ctx context.Context, conn Queryer, c Config) ([]string, error) {
+	_, err := conn.ExecContext(ctx, "SET")
+	return []string{"RESET"}, err
+}
+
+func (fakeDialect) Check(ctx context.Context, q Queryer) error {
+	_, err := q.ExecContext(ctx, "CHECK")
+	return err
+}
+
+// recorder is a database/sql connector logging the statements it runs. Its
+// queries return no rows, except for taking the lock, which always succeeds.
+type recorder struct {
+	mu  sync.Mutex
+	log []string
+}
+
+func (r *recorder) record(query string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.log = append(r.log, strings.Join(strings.Fields(query), " "))
+}
+
+func (r *recorder) Connect(ctx context.Context) (driver.Conn, error) { return r, nil }
+func (r *recorder) Driver() driver.Driver                            { return nil }
+func (r *recorder) Prepare(query string) (driver.Stmt, error)        { return &recorderStmt{r, query}, nil }
+func (r *recorder) Close() error                                     { return nil }
+func (r *recorder) Begin() (driver.Tx, error)                        { r.record("BEGIN"); return r, nil }
+func (r *recorder) Commit() error                                    { r.record("COMMIT"); return nil }
+func (r *recorder) Rollback() error                                  { r.record("ROLLBACK"); return nil }
+
+type recorderStmt struct {
+	r     *recorder
+	query string
+}
+
+func (s *recorderStmt) Close() error  { return nil }
+func (s *recorderStmt) NumInput() int { return -1 }
+
+func (s *recorderStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.r.record(s.query)
+	return driver.RowsAffected(0), nil
+}
+
+func (s *recorderStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.r.record(s.query)
+	if s.query == "LOCK" {
+		return &recorderRows{columns: []string{"locked"}, values: []driver.Value{true}}, nil
+	}
+	return &recorderRows{columns: make([]string, 9)}, nil
+}
+
+type recorderRows struct {
+	columns []string
+	values  []driver.Value
+}
+
+func (r *recorderRows) Columns() []string { return r.columns }
+func (r *recorderRows) Close() error      { return nil }
+
+func (r *recorderRows) Next(dest []driver.Value) error {
+	if r.values == nil {
+		return io.EOF
+	}
+	copy(dest, r.values)
+	r.values = nil
+	return nil
+}
+
+func TestSQLDriver(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0001_one_up.sql":   {Data: []byte("up 1")},
+		"0001_one_down.sql": {Data: []byte("down 1")},
+	}
+	assetFunc := func(name string) ([]byte, error) {
+		return fsys.ReadFile(name)
+	}
+
+	columns := "id, name, filename, up, down, status, checksum, out_of_order, sequence, duration_ms, db_user, hostname, app_version, created_at, updated_at"
+	list := "SELECT " + columns + " FROM [migrations] ORDER BY id DESC"
+
+	for _, transactional := range []bool{true, false} {
+		r := new(recorder)
+		db := sql.OpenDB(r)
+		c := Config{TableName: "migrations", LockWait: time.Second, Logger: slog.Default()}
+
+		m := &engine{
+			driver:    NewSQLDriver(db, fakeDialect{transactional}, c),
+			paths:     []string{"0001_one_down.sql", "0001_one_up.sql"},
+			assetFunc: assetFunc,
+			logger:    slog.Default(),
+		}
+
+		err := m.Init()
+		assert.Ok(t, err)
+
+		err = m.Migrate()
+		assert.Ok(t, err)
+
+		_, err = m.Migrations("0001", "0002")
+		assert.Ok(t, err)
+
+		history := "INSERT INTO [migrations_history] ( migration_id, name, filename, direction, checksum, out_of_order, duration_ms, hostname, app_version, db_user ) VALUES (@p1, @p2, @p3, @p4, @p5, @p6, @p7, @p8, @p9, USER)"
+		migrate := []string{"SET", "up 1", "CHECK", "BEGIN", "UPSERT [migrations]", history, "COMMIT", "RESET"}
+		if transactional {
+			migrate = []string{"SET", "BEGIN", "up 1", "CHECK", "UPSERT [migrations]", history, "COMMIT", "RESET"}
+		}
+
+		want := []string{"LOCK", "CREATE [migrations]", "CREATE [migrations_history]", "SELECT * FROM [migrations] WHERE 1 = 0"}
+		for _, c := range addedColumns {
+			want = append(want, "ADD "+c)
+		}
+		want = append(want, "UNLOCK", "LOCK", list)
+		want = append(want, migrate...)
+		want = append(want, "UNLOCK", "SELECT "+columns+" FROM [migrations] WHERE id IN (@p1, @p2) ORDER BY id DESC")
+		assert.Equals(t, want, r.log)
+	}
+}
+
+func TestSQLDriverSingleConnection(t *testing.T) {
+	db := sql.OpenDB(new(recorder))
+	db.SetMaxOpenConns(1)
+
+	d := NewSQLDriver(db, fakeDialect{}, Config{TableName: "migrations", LockWait: time.Second, Logger: slog.Default()})
+	_, err := d.Lock(context.Background())
+	assert.Cond(t, errors.Is(err, ErrLockFailed), "expected ErrLockFailed, got %v", err)
+}
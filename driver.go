@@ -0,0 +1,137 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package migrator
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Driver is implemented by database backends. It only deals with storing and
+// locking, which migrations to run and in what order is decided by the
+// Migrator returned by NewMigrator, so every database behaves the same.
+//
+// Backends living in other packages make themselves available by calling
+// Register from their init function:
+//
+//	func init() {
+//		migrator.Register("cockroach", NewDriver)
+//	}
+//
+// Bootstrap, Lock, Begin and the methods of the Tx returned by Begin are never
+// called concurrently. List and History aren't serialized, they may run at the
+// same time as each other and as a migration in progress, for instance when
+// listing applied migrations or planning.
+type Driver interface {
+	// Bootstrap creates the migrations table, or brings it up to date, if
+	// needed. It is called by Init.
+	Bootstrap(ctx context.Context) error
+	// Lock acquires the lock serializing migrations across every process
+	// sharing the database and returns the function releasing it. It gives up
+	// with ErrLockTimeout once Config.LockWait elapses and returns ErrLockFailed
+	// on any other failure. The release must go through even if ctx is
+	// canceled by then.
+	Lock(ctx context.Context) (unlock func(), err error)
+	// Begin starts the transaction a migration is run and recorded in, with
+	// the statement and lock timeouts of Config applied. When transactional
+	// is false, the migration opted out of running in a transaction, so its
+	// statements must run straight away.
+	Begin(ctx context.Context, transactional bool) (Tx, error)
+	// List returns the migrations recorded in the migrations table, or only
+	// those with the given IDs, in any order. The checksum is left empty for
+	// migrations recorded before checksums were.
+	List(ctx context.Context, ids ...string) ([]*Migration, error)
+	// History returns every migration applied or reverted, or only those with
+	// the given IDs, in the order it happened.
+	History(ctx context.Context, ids ...string) ([]*HistoryEntry, error)
+}
+
+// Tx runs and records a single migration. Databases unable to roll back
+// schema changes may run them right away, in which case Rollback only
+// releases the resources held by the Tx.
+type Tx interface {
+	// Apply runs the SQL of a migration, which may hold several statements.
+	Apply(ctx context.Context, query string) error
+	// ApplyFunc runs a migration written in Go, handing it the transaction.
+	// Databases unable to roll back schema changes start one just for it.
+	ApplyFunc(ctx context.Context, fn MigrationFunc) error
+	// Record stores m in the migrations table with the given status, inserting
+	// it if it isn't there yet and updating it otherwise, and appends the
+	// transition to the history, both at once.
+	Record(ctx context.Context, m *Migration, status Direction) error
+	// Commit makes the migration and its record permanent.
+	Commit() error
+	// Rollback discards the migration and its record.
+	Rollback() error
+}
+
+// Config holds the options a Driver is created with.
+type Config struct {
+	// TableName is the name of the migrations table.
+	TableName string
+	// Schema is the schema, or database, the migrations table lives in. Empty
+	// means the default one of the connection.
+	Schema string
+	// LockKey identifies the lock taken by Driver.Lock.
+	LockKey int64
+	// LockWait is how long Driver.Lock waits for the lock.
+	LockWait time.Duration
+	// StatementTimeout and LockTimeout bound the statements and lock waits of
+	// every migration when greater than zero.
+	StatementTimeout time.Duration
+	LockTimeout      time.Duration
+	// Logger receives events worth reporting, such as failing to release the
+	// lock.
+	Logger Logger
+}
+
+// DriverFactory creates a Driver for db.
+type DriverFactory func(db *sql.DB, c Config) (Driver, error)
+
+var (
+	driversMu sync.RWMutex
+	drivers   = make(map[DBType]DriverFactory)
+)
+
+// Register makes a database available to NewMigrator under dbType. It panics
+// if factory is nil or if dbType is already registered.
+func Register(dbType DBType, factory DriverFactory) {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+
+	if factory == nil {
+		panic("migrator: Register factory is nil")
+	}
+
+	if _, dup := drivers[dbType]; dup {
+		panic("migrator: Register called twice for database " + string(dbType))
+	}
+	drivers[dbType] = factory
+}
+
+// Drivers returns the sorted list of registered databases.
+func Drivers() []DBType {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+
+	list := make([]DBType, 0, len(drivers))
+	for dbType := range drivers {
+		list = append(list, dbType)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i] < list[j] })
+	return list
+}
+
+// registered returns the factory registered for dbType.
+func registered(dbType DBType) (DriverFactory, bool) {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+
+	factory, ok := drivers[dbType]
+	return factory, ok
+}
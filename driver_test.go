@@ -0,0 +1,384 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package migrator
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/hooklift/assert"
+)
+
+const fakeDB DBType = "fake"
+
+func init() {
+	Register(fakeDB, func(db *sql.DB, c Config) (Driver, error) {
+		return &fakeDriver{rows: make(map[string]Migration)}, nil
+	})
+}
+
+// fakeDriver keeps the migrations and history tables in memory and records the
+// SQL it runs.
+type fakeDriver struct {
+	rows    map[string]Migration
+	history []*HistoryEntry
+	ran     []string
+	// fail makes Apply fail when running this query.
+	fail string
+	// caseless makes List order IDs ignoring case, like some collations do.
+	caseless bool
+}
+
+func (d *fakeDriver) Bootstrap(ctx context.Context) error {
+	return nil
+}
+
+func (d *fakeDriver) Lock(ctx context.Context) (func(), error) {
+	return func() {}, nil
+}
+
+func (d *fakeDriver) Begin(ctx context.Context, transactional bool) (Tx, error) {
+	return &fakeTx{driver: d}, nil
+}
+
+func (d *fakeDriver) List(ctx context.Context, ids ...string) ([]*Migration, error) {
+	var ms []*Migration
+	for id, row := range d.rows {
+		if len(ids) > 0 && id != ids[0] {
+			continue
+		}
+		m := row
+		ms = append(ms, &m)
+	}
+	sort.Slice(ms, func(i, j int) bool {
+		if d.caseless {
+			return strings.ToLower(ms[i].ID) > strings.ToLower(ms[j].ID)
+		}
+		return ms[i].ID > ms[j].ID
+	})
+	return ms, nil
+}
+
+func (d *fakeDriver) History(ctx context.Context, ids ...string) ([]*HistoryEntry, error) {
+	var history []*HistoryEntry
+	for _, h := range d.history {
+		if len(ids) > 0 && h.ID != ids[0] {
+			continue
+		}
+		history = append(history, h)
+	}
+	return history, nil
+}
+
+type fakeTx struct {
+	driver  *fakeDriver
+	ran     []string
+	rows    []Migration
+	history []*HistoryEntry
+}
+
+func (tx *fakeTx) Apply(ctx context.Context, query string) error {
+	if query == tx.driver.fail {
+		return errors.New("syntax error")
+	}
+	tx.ran = append(tx.ran, query)
+	return nil
+}
+
+func (tx *fakeTx) ApplyFunc(ctx context.Context, fn MigrationFunc) error {
+	return fn(ctx, nil)
+}
+
+func (tx *fakeTx) Record(ctx context.Context, m *Migration, status Direction) error {
+	row := *m
+	row.Status = string(status)
+	tx.rows = append(tx.rows, row)
+	tx.history = append(tx.history, &HistoryEntry{ID: m.ID, Name: m.Name, Direction: status})
+	return nil
+}
+
+func (tx *fakeTx) Commit() error {
+	tx.driver.ran = append(tx.driver.ran, tx.ran...)
+	for _, row := range tx.rows {
+		tx.driver.rows[row.ID] = row
+	}
+	tx.driver.history = append(tx.driver.history, tx.history...)
+	return nil
+}
+
+func (tx *fakeTx) Rollback() error {
+	return nil
+}
+
+func TestRegister(t *testing.T) {
+	defer func() {
+		assert.Cond(t, recover() != nil, "expected registering fake twice to panic")
+	}()
+
+	assert.Cond(t, len(Drivers()) > 0, "expected fake to be registered")
+
+	_, err := NewMigratorFS(new(sql.DB), "bogus", fstest.MapFS{}, ".")
+	assert.Equals(t, ErrDBNotSupported, err)
+
+	Register(fakeDB, func(db *sql.DB, c Config) (Driver, error) {
+		return nil, nil
+	})
+}
+
+func TestEngine(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0001_one_up.sql":   {Data: []byte("up 1")},
+		"0001_one_down.sql": {Data: []byte("down 1")},
+		"0002_two_up.sql":   {Data: []byte("up 2")},
+		"0002_two_down.sql": {Data: []byte("down 2")},
+	}
+
+	m, err := NewMigratorFS(new(sql.DB), fakeDB, fsys, ".")
+	assert.Ok(t, err)
+	d := m.(*engine).driver.(*fakeDriver)
+
+	err = m.Migrate()
+	assert.Ok(t, err)
+	assert.Equals(t, []string{"up 1", "up 2"}, d.ran)
+	assert.Equals(t, int64(2), d.rows["0002"].Sequence)
+
+	err = m.Redo()
+	assert.Ok(t, err)
+	assert.Equals(t, []string{"up 1", "up 2", "down 2", "up 2"}, d.ran)
+	assert.Equals(t, int64(1), d.rows["0001"].Sequence)
+	assert.Equals(t, int64(3), d.rows["0002"].Sequence)
+
+	history, err := m.History("0002")
+	assert.Ok(t, err)
+	assert.Equals(t, 3, len(history))
+	assert.Equals(t, DirectionUp, history[0].Direction)
+	assert.Equals(t, DirectionDown, history[1].Direction)
+	assert.Equals(t, DirectionUp, history[2].Direction)
+
+	err = m.Rollback(5)
+	assert.Ok(t, err)
+	assert.Equals(t, []string{"up 1", "up 2", "down 2", "up 2", "down 2", "down 1"}, d.ran)
+	assert.Equals(t, "down", d.rows["0001"].Status)
+
+	err = m.Up("0002")
+	assert.Ok(t, err)
+	assert.Equals(t, "up", d.rows["0002"].Status)
+	assert.Equals(t, "down", d.rows["0001"].Status)
+
+	err = m.MigrateTo("0001")
+	assert.Ok(t, err)
+	assert.Equals(t, "up", d.rows["0001"].Status)
+	assert.Equals(t, "down", d.rows["0002"].Status)
+
+	err = m.MigrateTo("0003")
+	assert.Cond(t, errors.Is(err, ErrMigrationNotFound), "expected ErrMigrationNotFound, got %v", err)
+
+	err = m.Migrate()
+	assert.Ok(t, err)
+
+	steps, err := m.PlanRollbackTo("0001")
+	assert.Ok(t, err)
+	assert.Equals(t, 1, len(steps))
+	assert.Equals(t, "0002", steps[0].ID)
+	assert.Equals(t, DirectionDown, steps[0].Direction)
+
+	err = m.RollbackTo("0001")
+	assert.Ok(t, err)
+	assert.Equals(t, "up", d.rows["0001"].Status)
+	assert.Equals(t, "down", d.rows["0002"].Status)
+
+	err = m.RollbackTo("0003")
+	assert.Cond(t, errors.Is(err, ErrMigrationNotFound), "expected ErrMigrationNotFound, got %v", err)
+
+	_, err = m.PlanRollbackTo("0003")
+	assert.Cond(t, errors.Is(err, ErrMigrationNotFound), "expected ErrMigrationNotFound, got %v", err)
+
+	err = m.Migrate()
+	assert.Ok(t, err)
+
+	err = m.Reset()
+	assert.Ok(t, err)
+	assert.Equals(t, "down", d.rows["0001"].Status)
+	assert.Equals(t, "down", d.rows["0002"].Status)
+	assert.Equals(t, []string{"down 2", "down 1"}, d.ran[len(d.ran)-2:])
+
+	d.fail = "up 1"
+	err = m.Migrate()
+	assert.Cond(t, errors.Is(err, ErrMigrationFailed), "expected ErrMigrationFailed, got %v", err)
+	assert.Equals(t, "down", d.rows["0001"].Status)
+
+	var merr *MigrationError
+	assert.Cond(t, errors.As(err, &merr), "expected *MigrationError, got %T", err)
+	assert.Equals(t, "0001", merr.ID)
+	assert.Equals(t, PhaseExec, merr.Phase)
+	assert.Equals(t, "up 1", merr.SQL)
+}
+
+func TestGoMigration(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0001_one_up.sql":     {Data: []byte("up 1")},
+		"0001_one_down.sql":   {Data: []byte("down 1")},
+		"0003_three_up.sql":   {Data: []byte("up 3")},
+		"0003_three_down.sql": {Data: []byte("down 3")},
+	}
+
+	var d *fakeDriver
+	up := func(ctx context.Context, tx *sql.Tx) error {
+		d.ran = append(d.ran, "go up 2")
+		return nil
+	}
+
+	m, err := NewMigratorFS(new(sql.DB), fakeDB, fsys, ".", WithGoMigration("0002", "backfill", up, nil))
+	assert.Ok(t, err)
+	d = m.(*engine).driver.(*fakeDriver)
+
+	err = m.Migrate()
+	assert.Ok(t, err)
+	assert.Equals(t, []string{"up 1", "go up 2", "up 3"}, d.ran)
+	assert.Equals(t, "backfill", d.rows["0002"].Name)
+	assert.Equals(t, "", d.rows["0002"].Filename)
+
+	err = m.Rollback(2)
+	assert.Ok(t, err)
+	assert.Equals(t, []string{"up 1", "go up 2", "up 3", "down 3"}, d.ran)
+	assert.Equals(t, "down", d.rows["0002"].Status)
+
+	err = m.Up("0002")
+	assert.Ok(t, err)
+	assert.Equals(t, []string{"up 1", "go up 2", "up 3", "down 3", "go up 2"}, d.ran)
+
+	steps, err := m.Plan(CommandMigrate)
+	assert.Ok(t, err)
+	assert.Equals(t, 1, len(steps))
+	assert.Equals(t, "0003", steps[0].ID)
+
+	_, err = NewMigratorFS(new(sql.DB), fakeDB, fsys, ".", WithGoMigration("0003", "three", up, nil))
+	assert.Cond(t, errors.Is(err, ErrDuplicateMigration), "expected ErrDuplicateMigration, got %v", err)
+}
+
+func TestOutOfOrder(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0001_one_up.sql":     {Data: []byte("up 1")},
+		"0001_one_down.sql":   {Data: []byte("down 1")},
+		"0003_three_up.sql":   {Data: []byte("up 3")},
+		"0003_three_down.sql": {Data: []byte("down 3")},
+	}
+
+	m, err := NewMigratorFS(new(sql.DB), fakeDB, fsys, ".")
+	assert.Ok(t, err)
+	d := m.(*engine).driver.(*fakeDriver)
+
+	err = m.Migrate()
+	assert.Ok(t, err)
+
+	// 0002 is merged from a branch after 0003 was applied.
+	fsys["0002_two_up.sql"] = &fstest.MapFile{Data: []byte("up 2")}
+	fsys["0002_two_down.sql"] = &fstest.MapFile{Data: []byte("down 2")}
+
+	m, err = NewMigratorFS(new(sql.DB), fakeDB, fsys, ".")
+	assert.Ok(t, err)
+	m.(*engine).driver = d
+
+	steps, err := m.Plan(CommandMigrate)
+	assert.Ok(t, err)
+	assert.Equals(t, 1, len(steps))
+	assert.Cond(t, steps[0].OutOfOrder, "expected 0002 to be out of order")
+
+	err = m.Migrate()
+	assert.Cond(t, errors.Is(err, ErrOutOfOrder), "expected ErrOutOfOrder, got %v", err)
+	assert.Equals(t, []string{"up 1", "up 3"}, d.ran)
+
+	m, err = NewMigratorFS(new(sql.DB), fakeDB, fsys, ".", WithOutOfOrder(OutOfOrderWarn))
+	assert.Ok(t, err)
+	m.(*engine).driver = d
+
+	err = m.Migrate()
+	assert.Ok(t, err)
+	assert.Equals(t, []string{"up 1", "up 3", "up 2"}, d.ran)
+	assert.Cond(t, d.rows["0002"].OutOfOrder, "expected 0002 to be recorded out of order")
+	assert.Cond(t, !d.rows["0003"].OutOfOrder, "expected 0003 to be recorded in order")
+
+	// Applied again after 0003 was reverted, 0002 is back in order.
+	err = m.Rollback(2)
+	assert.Ok(t, err)
+
+	err = m.Migrate()
+	assert.Ok(t, err)
+	assert.Equals(t, []string{"up 1", "up 3", "up 2", "down 3", "down 2", "up 2", "up 3"}, d.ran)
+	assert.Cond(t, !d.rows["0002"].OutOfOrder, "expected 0002 to be recorded in order")
+}
+
+func TestDownThenMigrate(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0001_one_up.sql":     {Data: []byte("up 1")},
+		"0001_one_down.sql":   {Data: []byte("down 1")},
+		"0002_two_up.sql":     {Data: []byte("up 2")},
+		"0002_two_down.sql":   {Data: []byte("down 2")},
+		"0003_three_up.sql":   {Data: []byte("up 3")},
+		"0003_three_down.sql": {Data: []byte("down 3")},
+	}
+
+	m, err := NewMigratorFS(new(sql.DB), fakeDB, fsys, ".")
+	assert.Ok(t, err)
+	d := m.(*engine).driver.(*fakeDriver)
+
+	err = m.MigrateTo("0002")
+	assert.Ok(t, err)
+
+	// Taken down on its own, 0001 was applied before, so it isn't out of
+	// order when applied again along with 0003.
+	err = m.Down("0001")
+	assert.Ok(t, err)
+
+	steps, err := m.Plan(CommandMigrate)
+	assert.Ok(t, err)
+	assert.Equals(t, 2, len(steps))
+	assert.Cond(t, !steps[0].OutOfOrder, "expected 0001 to be in order")
+
+	err = m.Migrate()
+	assert.Ok(t, err)
+	assert.Equals(t, []string{"up 1", "up 2", "down 1", "up 1", "up 3"}, d.ran)
+	assert.Cond(t, !d.rows["0001"].OutOfOrder, "expected 0001 to be recorded in order")
+
+	err = m.Down("0002")
+	assert.Ok(t, err)
+
+	err = m.MigrateTo("0003")
+	assert.Ok(t, err)
+	assert.Equals(t, "up", d.rows["0002"].Status)
+}
+
+func TestCollation(t *testing.T) {
+	fsys := fstest.MapFS{
+		"v1_one_up.sql":   {Data: []byte("up 1")},
+		"v1_one_down.sql": {Data: []byte("down 1")},
+		"V2_two_up.sql":   {Data: []byte("up 2")},
+		"V2_two_down.sql": {Data: []byte("down 2")},
+	}
+
+	m, err := NewMigratorFS(new(sql.DB), fakeDB, fsys, ".")
+	assert.Ok(t, err)
+	d := m.(*engine).driver.(*fakeDriver)
+	d.caseless = true
+
+	// V2 runs first in byte order, even though the database lists it last.
+	err = m.Migrate()
+	assert.Ok(t, err)
+	assert.Equals(t, []string{"up 2", "up 1"}, d.ran)
+
+	ms, err := m.Migrations()
+	assert.Ok(t, err)
+	assert.Equals(t, "v1", ms[0].ID)
+
+	err = m.Rollback()
+	assert.Ok(t, err)
+	assert.Equals(t, []string{"up 2", "up 1", "down 1"}, d.ran)
+	assert.Equals(t, "up", d.rows["V2"].Status)
+}
@@ -0,0 +1,12 @@
+module github.com/c4milo/migrator
+
+go 1.21.0
+
+require (
+	github.com/go-sql-driver/mysql v1.9.3
+	github.com/hooklift/assert v0.0.0-20170704181755-9d1defd6d214
+	github.com/lib/pq v1.9.0
+	github.com/mattn/go-sqlite3 v1.14.33
+)
+
+require filippo.io/edwards25519 v1.1.0 // indirect
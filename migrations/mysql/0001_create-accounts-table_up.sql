@@ -0,0 +1,9 @@
+-- this table contains the all accounts of this system.
+create table if not exists accounts (
+	id         bigint       not null auto_increment,
+	email      varchar(255) not null,
+	created_at timestamp    not null default current_timestamp,
+
+	primary key (id),
+	unique key accounts_email_key (email)
+);
@@ -0,0 +1,11 @@
+-- this table contains the access tokens issued to accounts.
+create table if not exists tokens (
+	id         varchar(255) not null,
+	account_id bigint       not null,
+	expires_at timestamp    null,
+
+	primary key (id),
+	constraint tokens_account_id_fkey foreign key (account_id) references accounts (id) on delete cascade
+);
+
+create index tokens_expires_at_idx on tokens (expires_at);
@@ -0,0 +1,7 @@
+-- this table contains the all accounts of this system.
+create table accounts (
+	id         integer primary key,
+	email      text    not null unique,
+	nickname   text,
+	created_at timestamp not null default current_timestamp
+);
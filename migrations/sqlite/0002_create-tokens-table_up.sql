@@ -0,0 +1,8 @@
+-- this table contains the access tokens issued to accounts.
+create table tokens (
+	id         text    primary key,
+	account_id integer not null references accounts (id) on delete cascade,
+	expires_at timestamp
+);
+
+create index tokens_expires_at_idx on tokens (expires_at);
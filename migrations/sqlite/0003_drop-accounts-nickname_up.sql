@@ -0,0 +1,13 @@
+-- SQLite can't drop a column referenced by a constraint, the table has to be
+-- rebuilt without it, which tokens referencing accounts must survive.
+create table accounts_new (
+	id         integer primary key,
+	email      text    not null unique,
+	created_at timestamp not null default current_timestamp
+);
+
+insert into accounts_new (id, email, created_at)
+select id, email, created_at from accounts;
+
+drop table accounts;
+alter table accounts_new rename to accounts;
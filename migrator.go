@@ -5,13 +5,24 @@
 package migrator
 
 import (
+	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"errors"
-	"log"
-	"path/filepath"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"regexp"
+	"runtime/debug"
 	"sort"
 	"strings"
+	"sync"
 	"time"
+	"unicode"
 )
 
 var (
@@ -28,14 +39,135 @@ var (
 	ErrInvalidDB = errors.New("invalid-database-handle")
 	// ErrMigrationFailed is returned when a migration failed to run
 	ErrMigrationFailed = errors.New("migration-failed")
+	// ErrLockTimeout is returned when the migration lock could not be acquired
+	// within the configured wait time, usually because another process is
+	// running migrations against the same database.
+	ErrLockTimeout = errors.New("migration-lock-timeout")
+	// ErrLockFailed is returned when acquiring or releasing the migration lock
+	// fails for reasons other than a timeout.
+	ErrLockFailed = errors.New("migration-lock-failed")
+	// ErrInvalidCommand is returned when asking to plan an unknown command.
+	ErrInvalidCommand = errors.New("invalid-command")
+	// ErrChecksumMismatch is returned by Migrate in strict mode when a migration
+	// file was changed after it was applied.
+	ErrChecksumMismatch = errors.New("checksum-mismatch")
+	// ErrCreatingTable is returned when creating the migration table fails.
+	ErrCreatingTable = errors.New("error-creating-migration-table")
+	// ErrRegisteringMigration is returned when inserting an entry into the migration table fails.
+	ErrRegisteringMigration = errors.New("error-registering-migration")
+	// ErrGettingMigrations is returned when querying the migrations table fails.
+	ErrGettingMigrations = errors.New("error-getting-migrations")
+	// ErrRollbackFailed is returned if rolling back an actual migration fails.
+	ErrRollbackFailed = errors.New("error-rolling-back-migrations")
+	// ErrUpdatingMigration is returned when updating migration metadata fails.
+	ErrUpdatingMigration = errors.New("error-updating-migration-metadata")
+	// ErrRedoFailed is returned if redoing a migration fails for some reason.
+	ErrRedoFailed = errors.New("redo-error")
+	// ErrMigrationNotFound is returned when a migration was not found in the internal
+	// migrations table.
+	ErrMigrationNotFound = errors.New("migration-not-found")
+	// ErrMigrationIDrequired is returned if a migration is attempted to be created without an ID.
+	ErrMigrationIDrequired = errors.New("migration-id-required")
+	// ErrDownFailed is returned when taking down a migration fails.
+	ErrDownFailed = errors.New("migration-down-failed")
+	// ErrBadMigrationFile is returned when a single file migration lacks its
+	// -- +migrate Up or Down sections.
+	ErrBadMigrationFile = errors.New("bad-migration-file")
+	// ErrDuplicateMigration is returned when two migrations share the same ID.
+	ErrDuplicateMigration = errors.New("duplicate-migration-id")
+	// ErrGettingHistory is returned when querying the history table fails.
+	ErrGettingHistory = errors.New("error-getting-history")
+	// ErrOutOfOrder is returned by Migrate, MigrateTo and Redo when a pending
+	// migration is older than the latest applied one, unless allowed with
+	// WithOutOfOrder.
+	ErrOutOfOrder = errors.New("out-of-order-migration")
+	// ErrUnpairedMigration is returned by Validate when the up or down file of
+	// a migration is missing.
+	ErrUnpairedMigration = errors.New("unpaired-migration-file")
+	// ErrEmptyMigration is returned by Validate when a migration file, or one
+	// of its sections, holds nothing.
+	ErrEmptyMigration = errors.New("empty-migration")
+	// ErrAmbiguousOrder is returned by Validate when versions of different
+	// widths, such as 2 and 0010, would not run in numeric order.
+	ErrAmbiguousOrder = errors.New("ambiguous-migration-order")
+	// ErrBadMigrationID is returned by Validate when the ID of a Go migration
+	// isn't a version a migration file could start with.
+	ErrBadMigrationID = errors.New("bad-migration-id")
 )
 
+// Phase identifies the step of a migration that failed.
+type Phase string
+
+// Migration phases.
+const (
+	// PhaseValidate is checking the whole set of migrations before running any.
+	PhaseValidate Phase = "validate"
+	// PhaseDecode is reading and parsing the migration file.
+	PhaseDecode Phase = "decode"
+	// PhaseBegin is starting the migration transaction.
+	PhaseBegin Phase = "begin"
+	// PhaseExec is running the migration SQL.
+	PhaseExec Phase = "exec"
+	// PhaseRegister is recording the migration in the migrations table.
+	PhaseRegister Phase = "register"
+	// PhaseCommit is committing the migration transaction.
+	PhaseCommit Phase = "commit"
+)
+
+// MigrationError describes the failure to apply or revert a migration. It
+// matches, using errors.Is, the sentinel error that would have been returned
+// for the failure, for instance ErrMigrationFailed or ErrRollbackFailed, and
+// it unwraps to the underlying error, so database specific details can be
+// retrieved with errors.As.
+type MigrationError struct {
+	ID        string
+	Filename  string
+	Direction Direction
+	Phase     Phase
+	// SQL is the statement that failed, if any.
+	SQL string
+	// Err is the underlying error, usually the one returned by the driver.
+	Err error
+
+	kind error
+}
+
+func (e *MigrationError) Error() string {
+	msg := fmt.Sprintf("%s: migration %q", e.kind, e.ID)
+	if e.ID == "" {
+		msg = fmt.Sprintf("%s: migration file %q", e.kind, e.Filename)
+	}
+
+	msg += fmt.Sprintf(" failed to %s", e.Phase)
+	if e.Direction != "" {
+		msg += fmt.Sprintf(" %s", e.Direction)
+	}
+
+	if e.Err != nil {
+		msg += fmt.Sprintf(": %v", e.Err)
+	}
+	return msg
+}
+
+// Unwrap returns the underlying error.
+func (e *MigrationError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is the sentinel error describing e.
+func (e *MigrationError) Is(target error) bool {
+	return target == e.kind
+}
+
 // DBType defines a type for specifying the databasse to use during migration.
 type DBType string
 
-// Supported databases.
+// Databases supported by this package. Each is registered by its own file,
+// compiled in through the build tag of the same name.
 const (
 	Postgres DBType = "postgres"
+	MySQL    DBType = "mysql"
+	SQLite   DBType = "sqlite3"
 )
 
 // AssetFunc is the type that defines the function to access specific embedded files
@@ -50,19 +182,143 @@ type Migrator interface {
 	Init() error
 	// Migrate applies all migrations that hasn't been applied.
 	Migrate() error
+	// MigrateTo applies or reverts migrations so that the ones up to version,
+	// and only those, end up applied. version must be the ID of a migration.
+	MigrateTo(version string) error
 	// Redo undos specific migrations and applies them again. By default
 	// if no parameter is specified, it will redo the latest migration.
 	Redo(n ...uint) error
 	// Rollback reverts the last migration if not parameter is specified.
 	Rollback(n ...uint) error
+	// RollbackTo reverts the applied migrations with an ID greater than
+	// version, latest first. version must be the ID of a migration.
+	RollbackTo(version string) error
+	// Reset reverts every applied migration, latest first.
+	Reset() error
 	// Migrations returns the list of migrations currently applied to the database.
 	Migrations(ids ...string) ([]*Migration, error)
+	// History returns every time a migration was applied or reverted, or only
+	// those with the given IDs, oldest first.
+	History(ids ...string) ([]*HistoryEntry, error)
 	// Up applies a specific migration version.
 	Up(version string) error
 	// Down rolls back or takes down a specific migration version.
 	Down(version string) error
+	// Plan returns, in order, the steps the given command would run without
+	// running them. n is the number of steps for CommandRollback and
+	// CommandRedo, and it defaults to 1 as it does for Rollback and Redo.
+	Plan(cmd Command, n ...uint) ([]*Step, error)
+	// PlanTo returns, in order, the steps MigrateTo would run without running
+	// them.
+	PlanTo(version string) ([]*Step, error)
+	// PlanRollbackTo returns, in order, the steps RollbackTo would run without
+	// running them.
+	PlanRollbackTo(version string) ([]*Step, error)
+	// Verify compares the applied migrations against the migration files and
+	// returns the ones that no longer match.
+	Verify() ([]*Drift, error)
+	// Validate checks the migration files and Go migrations for problems, such
+	// as unparseable names, missing halves, duplicate IDs or empty files, and
+	// returns all of them joined with errors.Join. It is run by NewMigrator, so
+	// a broken set is rejected before any migration runs.
+	Validate() error
+
+	// The following variants take a context to allow canceling or bounding
+	// migrations with a deadline. The methods above are equivalent to calling
+	// these with context.Background().
+
+	// InitContext is like Init but honors ctx.
+	InitContext(ctx context.Context) error
+	// MigrateContext is like Migrate but honors ctx.
+	MigrateContext(ctx context.Context) error
+	// MigrateToContext is like MigrateTo but honors ctx.
+	MigrateToContext(ctx context.Context, version string) error
+	// RedoContext is like Redo but honors ctx.
+	RedoContext(ctx context.Context, n ...uint) error
+	// RollbackContext is like Rollback but honors ctx.
+	RollbackContext(ctx context.Context, n ...uint) error
+	// RollbackToContext is like RollbackTo but honors ctx.
+	RollbackToContext(ctx context.Context, version string) error
+	// ResetContext is like Reset but honors ctx.
+	ResetContext(ctx context.Context) error
+	// MigrationsContext is like Migrations but honors ctx.
+	MigrationsContext(ctx context.Context, ids ...string) ([]*Migration, error)
+	// HistoryContext is like History but honors ctx.
+	HistoryContext(ctx context.Context, ids ...string) ([]*HistoryEntry, error)
+	// UpContext is like Up but honors ctx.
+	UpContext(ctx context.Context, version string) error
+	// DownContext is like Down but honors ctx.
+	DownContext(ctx context.Context, version string) error
+	// PlanContext is like Plan but honors ctx.
+	PlanContext(ctx context.Context, cmd Command, n ...uint) ([]*Step, error)
+	// PlanToContext is like PlanTo but honors ctx.
+	PlanToContext(ctx context.Context, version string) ([]*Step, error)
+	// PlanRollbackToContext is like PlanRollbackTo but honors ctx.
+	PlanRollbackToContext(ctx context.Context, version string) ([]*Step, error)
+	// VerifyContext is like Verify but honors ctx.
+	VerifyContext(ctx context.Context) ([]*Drift, error)
+}
+
+// Direction indicates whether a migration is applied or reverted.
+type Direction string
+
+// Migration directions.
+const (
+	DirectionUp   Direction = "up"
+	DirectionDown Direction = "down"
+)
+
+// Command identifies a Migrator operation that can be planned.
+type Command string
+
+// Commands supported by Plan.
+const (
+	CommandMigrate  Command = "migrate"
+	CommandRollback Command = "rollback"
+	CommandRedo     Command = "redo"
+	CommandReset    Command = "reset"
+)
+
+// Step describes a migration that a command would apply or revert.
+type Step struct {
+	ID        string
+	Name      string
+	Filename  string
+	Direction Direction
+	// SQL is the content of the migration file when applying and the down
+	// migration recorded in the database when reverting, since that is what
+	// Rollback runs.
+	SQL string
+	// NoTransaction is set when SQL opts out of running in a transaction.
+	NoTransaction bool
+	// OutOfOrder is set when applying a migration older than the latest
+	// applied one by then.
+	OutOfOrder bool
 }
 
+// OutOfOrderPolicy tells what to do with pending migrations older than the
+// latest applied one, usually merged from a long-lived branch.
+type OutOfOrderPolicy int
+
+// Out of order policies.
+const (
+	// OutOfOrderError refuses to apply anything, returning ErrOutOfOrder.
+	OutOfOrderError OutOfOrderPolicy = iota
+	// OutOfOrderWarn applies them and logs a warning.
+	OutOfOrderWarn
+	// OutOfOrderAllow applies them silently.
+	OutOfOrderAllow
+)
+
+// NoTransactionDirective opts a migration out of running in a transaction
+// when found in the comments heading its SQL, for statements that can't run
+// in one, such as CREATE INDEX CONCURRENTLY in Postgres. Its statements are
+// then run one at a time and the migration is recorded once all of them
+// succeed. If one fails, those before it stay applied and the migration is
+// not recorded, so it runs again from the start next time. Such migrations
+// should therefore be safe to run again, for instance, using IF NOT EXISTS.
+const NoTransactionDirective = "-- migrator:no-transaction"
+
 // Migration represents an actual migration file.
 type Migration struct {
 	ID        string
@@ -71,39 +327,319 @@ type Migration struct {
 	Up        string
 	Down      string
 	Status    string
+	Checksum  string
 	CreatedAt time.Time `db:"created_at"`
 	UpdatedAt time.Time `db:"updated_at"`
+	// OutOfOrder is set when the migration was last applied after one with a
+	// greater ID.
+	OutOfOrder bool `db:"out_of_order"`
+	// Sequence tells the order in which migrations were last applied,
+	// starting at 1. Reverting a migration leaves it as is.
+	Sequence int64
+	// Duration is how long it took to last apply or revert the migration.
+	Duration time.Duration
+	// DBUser is the database user, Hostname the host and AppVersion the
+	// version of the application, set with WithAppVersion, that last applied
+	// or reverted the migration. DBUser is empty for databases without users,
+	// such as SQLite.
+	DBUser     string `db:"db_user"`
+	Hostname   string
+	AppVersion string `db:"app_version"`
+
+	// upFunc and downFunc are set for migrations written in Go, which have no
+	// file nor SQL.
+	upFunc   MigrationFunc
+	downFunc MigrationFunc
+}
+
+// HistoryEntry records a migration being applied or reverted. The history is
+// kept in a table named after the migrations table with a _history suffix, for
+// instance, schema_migrations_history, which is only ever appended to.
+type HistoryEntry struct {
+	// ID is the ID of the migration.
+	ID         string
+	Name       string
+	Filename   string
+	Direction  Direction
+	Checksum   string
+	OutOfOrder bool
+	Duration   time.Duration
+	DBUser     string
+	Hostname   string
+	AppVersion string
+	CreatedAt  time.Time
 }
 
+// MigrationFunc applies or reverts a migration written in Go within tx.
+type MigrationFunc func(ctx context.Context, tx *sql.Tx) error
+
 const baseDir string = ""
 
+// lockPollInterval is how often acquiring the cross-process migration lock is
+// retried while waiting for another process to release it.
+const lockPollInterval = 250 * time.Millisecond
+
+// Defaults used when no options are given to NewMigrator.
+const (
+	// DefaultTableName is the name of the table keeping track of migrations.
+	DefaultTableName = "schema_migrations"
+	// DefaultLockKey is the key used for the cross-process migration lock of
+	// the default migrations table. Other tables derive their key from their
+	// name unless one is given with WithLockKey.
+	DefaultLockKey int64 = 0x6d69677261746f72
+	// DefaultLockWait is how long to wait for the migration lock to be released
+	// by another process before giving up.
+	DefaultLockWait = time.Minute
+)
+
+// Logger receives structured events about the migrations being run, along with
+// key-value pairs such as the migration ID, direction and duration. It is
+// satisfied by *slog.Logger.
+type Logger interface {
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+// Option configures optional behavior of a Migrator.
+type Option func(*config)
+
+type config struct {
+	lockKey          int64
+	lockKeySet       bool
+	lockWait         time.Duration
+	statementTimeout time.Duration
+	lockTimeout      time.Duration
+	strictChecksums  bool
+	outOfOrder       OutOfOrderPolicy
+	logger           Logger
+	tableName        string
+	schema           string
+	baseDir          string
+	goMigrations     []*Migration
+	appVersion       string
+}
+
+func newConfig(opts []Option) *config {
+	c := &config{
+		lockKey:   DefaultLockKey,
+		lockWait:  DefaultLockWait,
+		logger:    slog.Default(),
+		tableName: DefaultTableName,
+		baseDir:   baseDir,
+	}
+
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "(devel)" {
+		c.appVersion = info.Main.Version
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	// Keeps independent migration histories in the same database from
+	// waiting on each other. The schema is left out, since an empty one may
+	// resolve to any other, so histories in different schemas only differ in
+	// key if their tables are named differently too.
+	if !c.lockKeySet && c.tableName != DefaultTableName {
+		h := fnv.New64a()
+		h.Write([]byte(c.tableName))
+		c.lockKey = int64(h.Sum64())
+	}
+	return c
+}
+
+// driverConfig returns the settings handed over to drivers.
+func (c *config) driverConfig() Config {
+	return Config{
+		TableName:        c.tableName,
+		Schema:           c.schema,
+		LockKey:          c.lockKey,
+		LockWait:         c.lockWait,
+		StatementTimeout: c.statementTimeout,
+		LockTimeout:      c.lockTimeout,
+		Logger:           c.logger,
+	}
+}
+
+// WithLockKey sets the key used for the cross-process migration lock. Services
+// sharing a database but keeping independent migration histories should use
+// different keys.
+func WithLockKey(key int64) Option {
+	return func(c *config) {
+		c.lockKey = key
+		c.lockKeySet = true
+	}
+}
+
+// WithLockWait sets how long to wait for the migration lock before returning
+// ErrLockTimeout.
+func WithLockWait(d time.Duration) Option {
+	return func(c *config) {
+		c.lockWait = d
+	}
+}
+
+// WithStatementTimeout aborts any statement of a migration that runs longer
+// than d. Databases unable to bound every statement, MySQL and SQLite, bound
+// the whole SQL of each migration file by d instead, while Go migrations are
+// left to their context. Zero, the default, leaves the database setting
+// untouched.
+func WithStatementTimeout(d time.Duration) Option {
+	return func(c *config) {
+		c.statementTimeout = d
+	}
+}
+
+// WithLockTimeout aborts a migration that waits longer than d to acquire a lock
+// on a table or row, for instance, while altering a table that is in heavy use.
+// It is applied to each migration transaction separately. Zero, the default,
+// leaves the database setting untouched.
+func WithLockTimeout(d time.Duration) Option {
+	return func(c *config) {
+		c.lockTimeout = d
+	}
+}
+
+// WithStrictChecksums makes Migrate fail with ErrChecksumMismatch, naming every
+// drifted migration and without applying anything, when applied migration
+// files were changed afterwards. By default the drift is only logged.
+func WithStrictChecksums() Option {
+	return func(c *config) {
+		c.strictChecksums = true
+	}
+}
+
+// WithOutOfOrder sets what Migrate, MigrateTo and Redo do with pending
+// migrations older than the latest applied one. By default they fail with
+// ErrOutOfOrder. Either way, migrations applied out of order are recorded as
+// such in the migrations table.
+func WithOutOfOrder(p OutOfOrderPolicy) Option {
+	return func(c *config) {
+		c.outOfOrder = p
+	}
+}
+
+// WithAppVersion sets the application version recorded along with the
+// migrations it applies or reverts. By default it is the version of the main
+// module, if the binary was built from a tagged module.
+func WithAppVersion(version string) Option {
+	return func(c *config) {
+		c.appVersion = version
+	}
+}
+
+// WithLogger sets the logger migration events are sent to. By default they go
+// to slog.Default().
+func WithLogger(l Logger) Option {
+	return func(c *config) {
+		c.logger = l
+	}
+}
+
+// WithTableName sets the name of the table keeping track of migrations.
+func WithTableName(name string) Option {
+	return func(c *config) {
+		c.tableName = name
+	}
+}
+
+// WithSchema sets the schema the migrations table lives in. The schema must
+// already exist. By default, the table is created in the first schema of the
+// search path.
+func WithSchema(schema string) Option {
+	return func(c *config) {
+		c.schema = schema
+	}
+}
+
+// WithBaseDir sets the directory, relative to the root of the assets, where
+// the migration files are looked up.
+func WithBaseDir(dir string) Option {
+	return func(c *config) {
+		c.baseDir = dir
+	}
+}
+
+// WithGoMigration adds a migration written in Go, for instance, to backfill
+// data in chunks. Its ID follows the rules of the versions of migration files.
+// It runs along with the migration files, in order of ID, and is recorded the
+// same way, with no SQL. down may be nil for migrations that have nothing to
+// undo. Rolling them back then only marks them as down.
+func WithGoMigration(id, name string, up, down MigrationFunc) Option {
+	return func(c *config) {
+		c.goMigrations = append(c.goMigrations, &Migration{
+			ID:       id,
+			Name:     name,
+			Checksum: checksum("", ""),
+			upFunc:   up,
+			downFunc: down,
+		})
+	}
+}
+
 // NewMigrator creates a new instance of the migration process, based on the database type provided.
-func NewMigrator(db *sql.DB, dbType DBType, assetFunc AssetFunc, assetDirFunc AssetDirFunc) (Migrator, error) {
+// Databases with advisory locks, such as Postgres and MySQL, hold the migration
+// lock on a connection of its own, so db must allow at least two open
+// connections.
+func NewMigrator(db *sql.DB, dbType DBType, assetFunc AssetFunc, assetDirFunc AssetDirFunc, opts ...Option) (Migrator, error) {
 	if db == nil {
 		return nil, ErrInvalidDB
 	}
 
-	paths, err := assetDirFunc(baseDir)
+	c := newConfig(opts)
+	paths, err := assetDirFunc(c.baseDir)
 	if err != nil {
-		log.Printf("[ERROR] trying to get list of migration files from embedded asset directory %s", baseDir)
-		log.Printf("[ERROR] %#v", err)
-		return nil, ErrMigrationFailed
+		return nil, fmt.Errorf("%w: listing migration files: %w", ErrMigrationFailed, err)
 	}
 
 	sort.Strings(paths)
 
-	var migrator Migrator
-	switch dbType {
-	case Postgres:
-		var err error
-		migrator, err = NewPostgres(db, paths, assetFunc)
-		if err != nil {
-			return nil, err
+	factory, ok := registered(dbType)
+	if !ok {
+		return nil, ErrDBNotSupported
+	}
+
+	goMigrations := make(map[string]*Migration, len(c.goMigrations))
+	for _, m := range c.goMigrations {
+		if m.ID == "" {
+			return nil, ErrMigrationIDrequired
 		}
+
+		if m.upFunc == nil {
+			return nil, fmt.Errorf("%w: go migration %q has no up function", ErrMigrationFailed, m.ID)
+		}
+
+		if _, dup := goMigrations[m.ID]; dup {
+			return nil, fmt.Errorf("%w: %s", ErrDuplicateMigration, m.ID)
+		}
+		goMigrations[m.ID] = m
 	}
 
-	if migrator == nil {
-		return nil, ErrDBNotSupported
+	if err := validate(c.baseDir, paths, assetFunc, goMigrations); err != nil {
+		return nil, err
+	}
+
+	d, err := factory(db, c.driverConfig())
+	if err != nil {
+		return nil, err
+	}
+
+	// Recorded as empty if unknown.
+	hostname, _ := os.Hostname()
+
+	migrator := &engine{
+		driver:          d,
+		paths:           paths,
+		assetFunc:       assetFunc,
+		goMigrations:    goMigrations,
+		baseDir:         c.baseDir,
+		strictChecksums: c.strictChecksums,
+		outOfOrder:      c.outOfOrder,
+		hostname:        hostname,
+		appVersion:      c.appVersion,
+		logger:          c.logger,
 	}
 
 	if err := migrator.Init(); err != nil {
@@ -113,38 +649,1201 @@ func NewMigrator(db *sql.DB, dbType DBType, assetFunc AssetFunc, assetDirFunc As
 	return migrator, nil
 }
 
-// DecodeFile takes a sql file and returns a Migration instance
-func DecodeFile(f string, assetFunc AssetFunc) (*Migration, error) {
-	// File names should be formatted like so: id_migration-name_up.sql or
-	// id_migration-name_down.sql. Ex: 0002_create-extension-citext_down.sql
-	parts := strings.Split(f, "_")
-	if len(parts) != 3 {
-		log.Printf("[ERROR] Bad file format: %q", f)
-		return nil, ErrBadFilenameFormat
+// NewMigratorFS creates a new instance of the migration process that reads the
+// migration files from the directory dir of fsys. This allows migrations to be
+// embedded with //go:embed or read straight from disk with os.DirFS.
+func NewMigratorFS(db *sql.DB, dbType DBType, fsys fs.FS, dir string, opts ...Option) (Migrator, error) {
+	if dir == "" {
+		dir = "."
 	}
 
-	m := new(Migration)
-	m.ID = parts[0]
-	m.Name = parts[1]
-	m.Filename = f
+	sub, err := fs.Sub(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("%w: opening migrations directory %s: %w", ErrMigrationFailed, dir, err)
+	}
 
-	upFile := filepath.Join(baseDir, f)
-	upSQL, err := assetFunc(upFile)
+	assetFunc := func(name string) ([]byte, error) {
+		return fs.ReadFile(sub, name)
+	}
+
+	assetDirFunc := func(name string) ([]string, error) {
+		if name == "" {
+			name = "."
+		}
+
+		entries, err := fs.ReadDir(sub, name)
+		if err != nil {
+			return nil, err
+		}
+
+		var names []string
+		for _, e := range entries {
+			if e.IsDir() {
+				continue
+			}
+			names = append(names, e.Name())
+		}
+		return names, nil
+	}
+
+	return NewMigrator(db, dbType, assetFunc, assetDirFunc, opts...)
+}
+
+// engine implements Migrator on top of a Driver. It decides which migrations
+// to run and in what order, leaving to the driver how they are stored.
+type engine struct {
+	sync.Mutex
+	driver          Driver
+	paths           []string
+	assetFunc       AssetFunc
+	goMigrations    map[string]*Migration
+	baseDir         string
+	strictChecksums bool
+	outOfOrder      OutOfOrderPolicy
+	hostname        string
+	appVersion      string
+	logger          Logger
+}
+
+// lock serializes migrations within this process and, through the driver,
+// across every process sharing the database. The returned function releases
+// both locks.
+func (e *engine) lock(ctx context.Context) (func(), error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	e.Lock()
+
+	unlock, err := e.driver.Lock(ctx)
+	if err != nil {
+		e.Unlock()
+		return nil, err
+	}
+
+	return func() {
+		unlock()
+		e.Unlock()
+	}, nil
+}
+
+// Init initializes the migration table.
+func (e *engine) Init() error {
+	return e.InitContext(context.Background())
+}
+
+// InitContext initializes the migration table.
+func (e *engine) InitContext(ctx context.Context) error {
+	unlock, err := e.lock(ctx)
 	if err != nil {
-		log.Printf("[ERROR] Extracting asset content from %s", upFile)
-		log.Printf("[ERROR] %#v", err)
-		return nil, ErrMigrationFailed
+		return err
 	}
+	defer unlock()
 
-	downFile := filepath.Join(baseDir, strings.Replace(f, "up.sql", "down.sql", 1))
-	downSQL, err := assetFunc(downFile)
+	if err := e.driver.Bootstrap(ctx); err != nil {
+		return fmt.Errorf("%w: %w", ErrCreatingTable, err)
+	}
+	return nil
+}
+
+// Up re-applies the specific migration ID only if the migration exists and has
+// status "down"
+func (e *engine) Up(id string) error {
+	return e.UpContext(context.Background(), id)
+}
+
+// UpContext re-applies the specific migration ID only if the migration exists
+// and has status "down"
+func (e *engine) UpContext(ctx context.Context, id string) error {
+	unlock, err := e.lock(ctx)
 	if err != nil {
-		log.Printf("[ERROR] Extracting asset content from %s", downFile)
-		log.Printf("[ERROR] %#v", err)
-		return nil, ErrMigrationFailed
+		return err
 	}
+	defer unlock()
 
-	m.Up = string(upSQL[:])
-	m.Down = string(downSQL[:])
-	return m, nil
+	ms, err := e.MigrationsContext(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if len(ms) == 0 {
+		return ErrMigrationNotFound
+	}
+
+	if ms[0].Status == string(DirectionUp) {
+		return nil
+	}
+
+	m, ok := e.goMigrations[id]
+	if !ok {
+		m, err = decodeFile(e.baseDir, ms[0].Filename, e.assetFunc)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Naming the migration to apply overrides the out of order policy, the
+	// outcome is recorded all the same.
+	applied, err := e.MigrationsContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	mu := *m
+	step := newStep(m, DirectionUp)
+	markOutOfOrder([]*Step{step}, applied)
+	mu.OutOfOrder, mu.Sequence = step.OutOfOrder, lastSequence(applied)+1
+	return e.apply(ctx, &mu, DirectionUp, true)
+}
+
+// Down takes down the migration identified by the given ID.
+func (e *engine) Down(id string) error {
+	return e.DownContext(context.Background(), id)
+}
+
+// DownContext takes down the migration identified by the given ID.
+func (e *engine) DownContext(ctx context.Context, id string) error {
+	if id == "" {
+		return ErrMigrationIDrequired
+	}
+
+	unlock, err := e.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	ms, err := e.driver.List(ctx, id)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrDownFailed, err)
+	}
+
+	if len(ms) == 0 || ms[0].Status != string(DirectionUp) {
+		return nil
+	}
+
+	return e.apply(ctx, e.withFuncs(ms[0]), DirectionDown, true)
+}
+
+// Redo re-runs a given number of latests migrations.
+func (e *engine) Redo(steps ...uint) error {
+	return e.RedoContext(context.Background(), steps...)
+}
+
+// RedoContext re-runs a given number of latests migrations.
+func (e *engine) RedoContext(ctx context.Context, steps ...uint) error {
+	return e.run(ctx, CommandRedo, steps...)
+}
+
+// Rollback removes a given number of latests migrations.
+func (e *engine) Rollback(steps ...uint) error {
+	return e.RollbackContext(context.Background(), steps...)
+}
+
+// RollbackContext removes a given number of latests migrations.
+func (e *engine) RollbackContext(ctx context.Context, steps ...uint) error {
+	return e.run(ctx, CommandRollback, steps...)
+}
+
+// RollbackTo removes the migrations applied after version.
+func (e *engine) RollbackTo(version string) error {
+	return e.RollbackToContext(context.Background(), version)
+}
+
+// RollbackToContext removes the migrations applied after version.
+func (e *engine) RollbackToContext(ctx context.Context, version string) error {
+	return e.runPlan(ctx, false, func(files, applied []*Migration) ([]*Step, error) {
+		return planRollbackTo(version, files, applied)
+	})
+}
+
+// Reset removes every applied migration.
+func (e *engine) Reset() error {
+	return e.ResetContext(context.Background())
+}
+
+// ResetContext removes every applied migration.
+func (e *engine) ResetContext(ctx context.Context) error {
+	return e.run(ctx, CommandReset)
+}
+
+// Migrate applies all migrations that haven't been applied yet.
+func (e *engine) Migrate() error {
+	return e.MigrateContext(context.Background())
+}
+
+// MigrateContext applies all migrations that haven't been applied yet.
+func (e *engine) MigrateContext(ctx context.Context) error {
+	return e.run(ctx, CommandMigrate)
+}
+
+// MigrateTo applies or reverts migrations until the database is at version.
+func (e *engine) MigrateTo(version string) error {
+	return e.MigrateToContext(context.Background(), version)
+}
+
+// MigrateToContext applies or reverts migrations until the database is at
+// version.
+func (e *engine) MigrateToContext(ctx context.Context, version string) error {
+	return e.runPlan(ctx, true, func(files, applied []*Migration) ([]*Step, error) {
+		return planTo(version, files, applied)
+	})
+}
+
+// run applies, in order, the steps planned for cmd.
+func (e *engine) run(ctx context.Context, cmd Command, steps ...uint) error {
+	check := cmd != CommandRollback && cmd != CommandReset
+	return e.runPlan(ctx, check, func(files, applied []*Migration) ([]*Step, error) {
+		return plan(cmd, count(steps), files, applied)
+	})
+}
+
+// planner computes the steps to run given the migration files and the
+// migrations recorded in the database.
+type planner func(files, applied []*Migration) ([]*Step, error)
+
+// runPlan applies, in order, the steps planned by p. Unless only reverting
+// migrations, check verifies that the applied migrations match their files.
+func (e *engine) runPlan(ctx context.Context, check bool, p planner) error {
+	unlock, err := e.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	files, err := e.files()
+	if err != nil {
+		return err
+	}
+
+	applied, err := e.MigrationsContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	if check {
+		var drifted []string
+		for _, d := range verify(files, applied) {
+			e.logger.Warn("migration changed after being applied", "id", d.ID, "checksum", d.Checksum, "applied_checksum", d.AppliedChecksum)
+			drifted = append(drifted, d.ID)
+		}
+		if e.strictChecksums && len(drifted) > 0 {
+			return fmt.Errorf("%w: %s", ErrChecksumMismatch, strings.Join(drifted, ", "))
+		}
+	}
+
+	todo, err := p(files, applied)
+	if err != nil {
+		return err
+	}
+
+	if latest := markOutOfOrder(todo, applied); latest != "" {
+		var ids []string
+		for _, s := range todo {
+			if s.OutOfOrder {
+				ids = append(ids, s.ID)
+			}
+		}
+
+		switch e.outOfOrder {
+		case OutOfOrderError:
+			return fmt.Errorf("%w: %s older than %s, which is applied", ErrOutOfOrder, strings.Join(ids, ", "), latest)
+		case OutOfOrderWarn:
+			e.logger.Warn("applying migrations out of order", "ids", ids, "latest", latest)
+		}
+	}
+
+	// Steps taking down a migration run what was recorded when applying it,
+	// while those applying one run its file.
+	ups := make(map[string]*Migration, len(files))
+	for _, m := range files {
+		ups[m.ID] = m
+	}
+
+	downs := make(map[string]*Migration, len(applied))
+	for _, m := range applied {
+		downs[m.ID] = m
+	}
+
+	seq := lastSequence(applied)
+	for _, s := range todo {
+		m := ups[s.ID]
+		if s.Direction == DirectionDown {
+			m = e.withFuncs(downs[s.ID])
+		} else {
+			seq++
+			mu := *m
+			mu.OutOfOrder, mu.Sequence = s.OutOfOrder, seq
+			m = &mu
+		}
+
+		_, exists := downs[s.ID]
+		if err := e.apply(ctx, m, s.Direction, exists); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// files returns the migration files along with the migrations written in Go,
+// sorted by ID.
+func (e *engine) files() ([]*Migration, error) {
+	files, err := decodeFiles(e.baseDir, e.paths, e.assetFunc)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, m := range files {
+		if _, dup := e.goMigrations[m.ID]; dup {
+			return nil, fmt.Errorf("%w: %s", ErrDuplicateMigration, m.ID)
+		}
+	}
+
+	for _, m := range e.goMigrations {
+		files = append(files, m)
+	}
+
+	sort.SliceStable(files, func(i, j int) bool { return files[i].ID < files[j].ID })
+	return files, nil
+}
+
+// withFuncs returns the recorded migration m along with its functions if it
+// was written in Go.
+func (e *engine) withFuncs(m *Migration) *Migration {
+	g, ok := e.goMigrations[m.ID]
+	if !ok {
+		return m
+	}
+
+	mg := *m
+	mg.upFunc, mg.downFunc = g.upFunc, g.downFunc
+	return &mg
+}
+
+// apply runs m in the given direction and records it. exists tells whether m
+// is already in the migrations table.
+func (e *engine) apply(ctx context.Context, m *Migration, dir Direction, exists bool) error {
+	query, fn, kind := m.Up, m.upFunc, ErrMigrationFailed
+	if dir == DirectionDown {
+		query, fn, kind = m.Down, m.downFunc, ErrRollbackFailed
+	}
+
+	start := time.Now()
+	fail := func(kind error, phase Phase, sql string, err error) error {
+		e.logger.Error("migration failed", "id", m.ID, "direction", dir, "phase", phase, "duration", time.Since(start), "error", err)
+		return &MigrationError{
+			ID:        m.ID,
+			Filename:  m.Filename,
+			Direction: dir,
+			Phase:     phase,
+			SQL:       sql,
+			Err:       err,
+			kind:      kind,
+		}
+	}
+
+	if m.Filename == "" && m.upFunc == nil {
+		// Recorded by a Go migration that is no longer registered.
+		return fail(kind, PhaseDecode, "", ErrMigrationNotFound)
+	}
+
+	noTx := fn == nil && noTransaction(query)
+	e.logger.Info("migration started", "id", m.ID, "direction", dir, "transaction", !noTx)
+
+	tx, err := e.driver.Begin(ctx, !noTx)
+	if err != nil {
+		return fail(kind, PhaseBegin, "", err)
+	}
+
+	switch {
+	case fn != nil:
+		err = tx.ApplyFunc(ctx, fn)
+	case noTx:
+		// Runs each statement on its own, since some databases wrap
+		// several statements sent at once in an implicit transaction.
+		for _, stmt := range splitStatements(query) {
+			if err = tx.Apply(ctx, stmt); err != nil {
+				query = stmt
+				break
+			}
+		}
+	case m.upFunc == nil:
+		err = tx.Apply(ctx, query)
+	}
+
+	if err != nil {
+		tx.Rollback()
+		return fail(kind, PhaseExec, query, err)
+	}
+
+	rec := *m
+	rec.Duration, rec.Hostname, rec.AppVersion = time.Since(start), e.hostname, e.appVersion
+	if err := tx.Record(ctx, &rec, dir); err != nil {
+		tx.Rollback()
+		if dir == DirectionUp {
+			kind = ErrRegisteringMigration
+			if exists {
+				kind = ErrUpdatingMigration
+			}
+		}
+
+		if noTx {
+			e.logger.Error("migration ran but could not be recorded, it will run again next time", "id", m.ID, "direction", dir)
+		}
+		return fail(kind, PhaseRegister, "", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fail(kind, PhaseCommit, "", err)
+	}
+
+	e.logger.Info("migration finished", "id", m.ID, "direction", dir, "duration", time.Since(start))
+	return nil
+}
+
+// Plan returns the steps cmd would run without running them.
+func (e *engine) Plan(cmd Command, steps ...uint) ([]*Step, error) {
+	return e.PlanContext(context.Background(), cmd, steps...)
+}
+
+// PlanContext returns the steps cmd would run without running them.
+func (e *engine) PlanContext(ctx context.Context, cmd Command, steps ...uint) ([]*Step, error) {
+	return e.plan(ctx, func(files, applied []*Migration) ([]*Step, error) {
+		return plan(cmd, count(steps), files, applied)
+	})
+}
+
+// PlanTo returns the steps MigrateTo would run without running them.
+func (e *engine) PlanTo(version string) ([]*Step, error) {
+	return e.PlanToContext(context.Background(), version)
+}
+
+// PlanToContext returns the steps MigrateTo would run without running them.
+func (e *engine) PlanToContext(ctx context.Context, version string) ([]*Step, error) {
+	return e.plan(ctx, func(files, applied []*Migration) ([]*Step, error) {
+		return planTo(version, files, applied)
+	})
+}
+
+// PlanRollbackTo returns the steps RollbackTo would run without running them.
+func (e *engine) PlanRollbackTo(version string) ([]*Step, error) {
+	return e.PlanRollbackToContext(context.Background(), version)
+}
+
+// PlanRollbackToContext returns the steps RollbackTo would run without running
+// them.
+func (e *engine) PlanRollbackToContext(ctx context.Context, version string) ([]*Step, error) {
+	return e.plan(ctx, func(files, applied []*Migration) ([]*Step, error) {
+		return planRollbackTo(version, files, applied)
+	})
+}
+
+// plan returns the steps planned by p without running them.
+func (e *engine) plan(ctx context.Context, p planner) ([]*Step, error) {
+	files, err := e.files()
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := e.MigrationsContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	steps, err := p(files, applied)
+	if err != nil {
+		return nil, err
+	}
+
+	markOutOfOrder(steps, applied)
+	return steps, nil
+}
+
+// Verify returns the applied migrations whose files changed afterwards.
+func (e *engine) Verify() ([]*Drift, error) {
+	return e.VerifyContext(context.Background())
+}
+
+// Validate checks the migration set for problems without touching the
+// database.
+func (e *engine) Validate() error {
+	return validate(e.baseDir, e.paths, e.assetFunc, e.goMigrations)
+}
+
+// VerifyContext returns the applied migrations whose files changed afterwards.
+func (e *engine) VerifyContext(ctx context.Context) ([]*Drift, error) {
+	files, err := e.files()
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := e.MigrationsContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return verify(files, applied), nil
+}
+
+// Migrations returns information about a list of migration IDs.
+func (e *engine) Migrations(IDs ...string) ([]*Migration, error) {
+	return e.MigrationsContext(context.Background(), IDs...)
+}
+
+// MigrationsContext returns information about a list of migration IDs.
+func (e *engine) MigrationsContext(ctx context.Context, IDs ...string) ([]*Migration, error) {
+	migrations, err := e.driver.List(ctx, IDs...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrGettingMigrations, err)
+	}
+
+	// Databases may order IDs by their collation, which doesn't necessarily
+	// agree with the byte order the migration files run in.
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].ID > migrations[j].ID
+	})
+
+	for _, m := range migrations {
+		if m.Checksum == "" {
+			// Applied before checksums were recorded, the stored SQL is
+			// what was run though.
+			m.Checksum = checksum(m.Up, m.Down)
+		}
+	}
+	return migrations, nil
+}
+
+// History returns the transitions of the given migrations IDs, or of all of
+// them.
+func (e *engine) History(IDs ...string) ([]*HistoryEntry, error) {
+	return e.HistoryContext(context.Background(), IDs...)
+}
+
+// HistoryContext returns the transitions of the given migrations IDs, or of
+// all of them.
+func (e *engine) HistoryContext(ctx context.Context, IDs ...string) ([]*HistoryEntry, error) {
+	history, err := e.driver.History(ctx, IDs...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrGettingHistory, err)
+	}
+	return history, nil
+}
+
+// count returns the number of steps given to Rollback, Redo or Plan, which
+// defaults to 1.
+func count(steps []uint) uint {
+	if len(steps) > 0 {
+		return steps[0]
+	}
+	return 1
+}
+
+// plan computes the steps cmd would run given the migration files and the
+// migrations recorded in the database. Files must be sorted in ascending
+// order and applied in descending order, like Migrations returns them.
+func plan(cmd Command, n uint, files, applied []*Migration) ([]*Step, error) {
+	var steps []*Step
+	switch cmd {
+	case CommandMigrate:
+	case CommandRedo:
+		if n > uint(len(files)) {
+			n = uint(len(files))
+		}
+		steps = rollback(applied, n, "")
+	case CommandRollback:
+		return rollback(applied, n, ""), nil
+	case CommandReset:
+		return rollback(applied, uint(len(applied)), ""), nil
+	default:
+		return nil, ErrInvalidCommand
+	}
+	return append(steps, pending(files, applied, steps, "")...), nil
+}
+
+// planTo computes the steps leaving applied the migrations up to version and
+// only those: the applied migrations past version are reverted, latest first,
+// and then the pending ones up to version are applied, in order. Files and
+// applied are sorted like they are for plan.
+func planTo(version string, files, applied []*Migration) ([]*Step, error) {
+	if !known(version, files) {
+		return nil, fmt.Errorf("%w: %s", ErrMigrationNotFound, version)
+	}
+
+	steps := rollback(applied, uint(len(applied)), version)
+	return append(steps, pending(files, applied, steps, version)...), nil
+}
+
+// planRollbackTo computes the steps reverting the applied migrations past
+// version, latest first. version must be the ID of a migration file or of a
+// recorded migration.
+func planRollbackTo(version string, files, applied []*Migration) ([]*Step, error) {
+	if !known(version, files, applied) {
+		return nil, fmt.Errorf("%w: %s", ErrMigrationNotFound, version)
+	}
+	return rollback(applied, uint(len(applied)), version), nil
+}
+
+// known reports whether any of the given migrations has the ID version.
+func known(version string, migrations ...[]*Migration) bool {
+	for _, ms := range migrations {
+		for _, m := range ms {
+			if m.ID == version {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// rollback returns the steps reverting, latest first, at most n of the applied
+// migrations with an ID greater than after, which is empty to go all the way
+// down. applied is sorted in descending order, like Migrations returns it.
+func rollback(applied []*Migration, n uint, after string) []*Step {
+	var steps []*Step
+	for _, m := range applied {
+		if n == 0 || m.ID <= after {
+			break
+		}
+
+		if m.Status != string(DirectionUp) {
+			continue
+		}
+
+		steps = append(steps, newStep(m, DirectionDown))
+		n--
+	}
+	return steps
+}
+
+// pending returns the steps applying, in order, the migration files up to
+// version, or all of them if empty, that aren't applied once the rollback
+// steps ran.
+func pending(files, applied []*Migration, rollback []*Step, version string) []*Step {
+	status := make(map[string]string, len(applied))
+	for _, m := range applied {
+		status[m.ID] = m.Status
+	}
+
+	for _, s := range rollback {
+		status[s.ID] = string(DirectionDown)
+	}
+
+	var steps []*Step
+	for _, m := range files {
+		if version != "" && m.ID > version {
+			break
+		}
+
+		if status[m.ID] != string(DirectionUp) {
+			steps = append(steps, newStep(m, DirectionUp))
+		}
+	}
+	return steps
+}
+
+// markOutOfOrder flags the steps applying a migration never recorded before
+// that is older than one applied by then, either before running steps or by a
+// previous step. Migrations taken down and applied again keep their place. It
+// returns the latest migration applied before running steps if any step was
+// flagged.
+func markOutOfOrder(steps []*Step, applied []*Migration) string {
+	up := make(map[string]bool, len(applied))
+	recorded := make(map[string]bool, len(applied))
+	latest := ""
+	for _, m := range applied {
+		recorded[m.ID] = true
+		if m.Status == string(DirectionUp) {
+			up[m.ID] = true
+			if m.ID > latest {
+				latest = m.ID
+			}
+		}
+	}
+
+	flagged := false
+	for _, s := range steps {
+		if s.Direction == DirectionDown {
+			delete(up, s.ID)
+			continue
+		}
+
+		for id := range up {
+			if !recorded[s.ID] && id > s.ID {
+				s.OutOfOrder, flagged = true, true
+				break
+			}
+		}
+		up[s.ID] = true
+	}
+
+	if !flagged {
+		return ""
+	}
+	return latest
+}
+
+// lastSequence returns the greatest sequence among the applied migrations, or
+// 0 if there are none.
+func lastSequence(applied []*Migration) int64 {
+	var seq int64
+	for _, m := range applied {
+		if m.Sequence > seq {
+			seq = m.Sequence
+		}
+	}
+	return seq
+}
+
+// newStep returns the step running m in the given direction. m is a migration
+// file when applying and a recorded migration when reverting.
+func newStep(m *Migration, dir Direction) *Step {
+	query := m.Up
+	if dir == DirectionDown {
+		query = m.Down
+	}
+
+	return &Step{
+		ID:            m.ID,
+		Name:          m.Name,
+		Filename:      m.Filename,
+		Direction:     dir,
+		SQL:           query,
+		NoTransaction: noTransaction(query),
+	}
+}
+
+// noTransaction reports whether the comments heading query hold the
+// NoTransactionDirective.
+func noTransaction(query string) bool {
+	for _, line := range strings.Split(query, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if !strings.HasPrefix(line, "--") {
+			return false
+		}
+
+		if strings.Join(strings.Fields(line[2:]), " ") == NoTransactionDirective[3:] {
+			return true
+		}
+	}
+	return false
+}
+
+// dollarQuote matches the opening tag of a Postgres dollar quoted string.
+var dollarQuote = regexp.MustCompile(`^\$([A-Za-z_][A-Za-z_0-9]*)?\$`)
+
+// splitStatements splits query into statements, leaving out those with
+// nothing but comments. Semicolons within quotes, Postgres dollar quoted
+// strings and comments don't split statements, backslash escapes within
+// quotes are not taken into account though.
+func splitStatements(query string) []string {
+	var stmts []string
+	start, code := 0, false
+	for i := 0; i < len(query); i++ {
+		skip := -1
+		switch c := query[i]; {
+		case c == '\'' || c == '"' || c == '`':
+			if skip = strings.IndexByte(query[i+1:], c); skip >= 0 {
+				skip++
+			}
+			code = true
+		case strings.HasPrefix(query[i:], "--"):
+			skip = strings.IndexByte(query[i:], '\n')
+		case strings.HasPrefix(query[i:], "/*"):
+			if skip = strings.Index(query[i+2:], "*/"); skip >= 0 {
+				skip += 3
+			}
+		case c == '$' && dollarQuote.MatchString(query[i:]):
+			tag := dollarQuote.FindString(query[i:])
+			if skip = strings.Index(query[i+len(tag):], tag); skip >= 0 {
+				skip += len(tag)*2 - 1
+			}
+			code = true
+		case c == ';':
+			if code {
+				stmts = append(stmts, strings.TrimSpace(query[start:i]))
+			}
+			start, code = i+1, false
+			continue
+		default:
+			if !unicode.IsSpace(rune(c)) {
+				code = true
+			}
+			continue
+		}
+
+		if skip < 0 {
+			// Unterminated, runs until the end.
+			break
+		}
+		i += skip
+	}
+
+	if code {
+		stmts = append(stmts, strings.TrimSpace(query[start:]))
+	}
+	return stmts
+}
+
+// Drift describes an applied migration whose file changed or disappeared.
+type Drift struct {
+	ID       string
+	Filename string
+	// Checksum is the checksum of the migration file, empty if the file no
+	// longer exists.
+	Checksum string
+	// AppliedChecksum is the checksum recorded when the migration was applied.
+	AppliedChecksum string
+}
+
+// checksum returns the hex encoded SHA-256 hash of the up and down SQL.
+func checksum(up, down string) string {
+	h := sha256.New()
+	h.Write([]byte(up))
+	// Separates both halves so moving SQL from one to the other is detected.
+	h.Write([]byte{0})
+	h.Write([]byte(down))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// verify returns the applied migrations whose checksum doesn't match the one
+// of their migration file.
+func verify(files, applied []*Migration) []*Drift {
+	checksums := make(map[string]string, len(files))
+	for _, m := range files {
+		checksums[m.ID] = m.Checksum
+	}
+
+	var drift []*Drift
+	for _, m := range applied {
+		if m.Status != string(DirectionUp) {
+			continue
+		}
+
+		if sum := checksums[m.ID]; sum != m.Checksum {
+			drift = append(drift, &Drift{
+				ID:              m.ID,
+				Filename:        m.Filename,
+				Checksum:        sum,
+				AppliedChecksum: m.Checksum,
+			})
+		}
+	}
+	return drift
+}
+
+// decodeFiles decodes every up migration found in paths, relative to dir.
+func decodeFiles(dir string, paths []string, assetFunc AssetFunc) ([]*Migration, error) {
+	var files []*Migration
+	for _, f := range paths {
+		if strings.HasSuffix(f, "_down.sql") {
+			continue
+		}
+
+		m, err := decodeFile(dir, f, assetFunc)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, m)
+	}
+	return files, nil
+}
+
+// validate returns every problem found in the migration files at paths,
+// relative to dir, and the Go migrations, joined with errors.Join.
+func validate(dir string, paths []string, assetFunc AssetFunc, goMigrations map[string]*Migration) error {
+	var errs []error
+	invalid := func(kind error, id, f string, d Direction, err error) {
+		errs = append(errs, &MigrationError{
+			ID:        id,
+			Filename:  f,
+			Direction: d,
+			Phase:     PhaseValidate,
+			Err:       err,
+			kind:      kind,
+		})
+	}
+
+	// Paired files are grouped by the part of their name preceding the
+	// direction, single files go by their whole name.
+	var names []string
+	halves := make(map[string]map[Direction]string)
+	byID := make(map[string][]string)
+	for _, f := range paths {
+		id, _, direction, err := parseFilename(f)
+		if err != nil {
+			invalid(ErrBadFilenameFormat, "", f, "", err)
+			continue
+		}
+
+		name := f
+		if direction != "" {
+			name = strings.TrimSuffix(f, "_"+string(direction)+".sql")
+		}
+
+		if halves[name] == nil {
+			halves[name] = make(map[Direction]string)
+			names = append(names, name)
+			byID[id] = append(byID[id], name)
+		}
+		halves[name][direction] = f
+
+		content, err := assetFunc(path.Join(dir, f))
+		if err != nil {
+			invalid(ErrMigrationFailed, "", f, direction, err)
+			continue
+		}
+
+		if direction != "" {
+			if strings.TrimSpace(string(content)) == "" {
+				invalid(ErrEmptyMigration, "", f, direction, errors.New("file is empty"))
+			}
+			continue
+		}
+
+		up, down, err := decodeSections(string(content))
+		if err != nil {
+			invalid(ErrBadMigrationFile, "", f, "", err)
+			continue
+		}
+
+		if strings.TrimSpace(strings.TrimPrefix(up, NoTransactionDirective)) == "" {
+			invalid(ErrEmptyMigration, "", f, DirectionUp, errors.New("up section is empty"))
+		}
+
+		if strings.TrimSpace(strings.TrimPrefix(down, NoTransactionDirective)) == "" {
+			invalid(ErrEmptyMigration, "", f, DirectionDown, errors.New("down section is empty"))
+		}
+	}
+
+	for _, name := range names {
+		h := halves[name]
+		if _, single := h[""]; single {
+			continue
+		}
+
+		if f, ok := h[DirectionUp]; !ok {
+			invalid(ErrUnpairedMigration, "", h[DirectionDown], DirectionUp, fmt.Errorf("missing %s_up.sql", name))
+		} else if _, ok := h[DirectionDown]; !ok {
+			invalid(ErrUnpairedMigration, "", f, DirectionDown, fmt.Errorf("missing %s_down.sql", name))
+		}
+	}
+
+	// Go migrations follow the same rules as the versions of files.
+	goIDs := make([]string, 0, len(goMigrations))
+	for id := range goMigrations {
+		goIDs = append(goIDs, id)
+	}
+	sort.Strings(goIDs)
+
+	for _, id := range goIDs {
+		if version, _, _, err := parseFilename(id + "_go.sql"); err != nil || version != id {
+			invalid(ErrBadMigrationID, id, "", "", errors.New("must not be empty or contain underscores"))
+			continue
+		}
+
+		if _, ok := byID[id]; !ok {
+			byID[id] = nil
+		}
+	}
+
+	ids := make([]string, 0, len(byID))
+	for id := range byID {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	// Only versions made of digits are expected to run in numeric order.
+	var prev string
+	for _, id := range ids {
+		files := byID[id]
+		if _, ok := goMigrations[id]; ok {
+			files = append(files, "a go migration")
+		}
+
+		if len(files) > 1 {
+			invalid(ErrDuplicateMigration, id, "", "", fmt.Errorf("used by %s", strings.Join(files, ", ")))
+		}
+
+		if !isDigits(id) {
+			continue
+		}
+
+		if prev != "" && numericLess(id, prev) {
+			invalid(ErrAmbiguousOrder, id, "", "", fmt.Errorf("sorts after %s but is numerically lower, pad versions to the same width", prev))
+		}
+		prev = id
+	}
+	return errors.Join(errs...)
+}
+
+// isDigits reports whether the version v is made of digits only.
+func isDigits(v string) bool {
+	return v != "" && strings.TrimFunc(v, unicode.IsDigit) == ""
+}
+
+// numericLess reports whether the version a is numerically lower than b. Both
+// are made of digits.
+func numericLess(a, b string) bool {
+	a, b = strings.TrimLeft(a, "0"), strings.TrimLeft(b, "0")
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return a < b
+}
+
+// DecodeFile takes a sql file and returns a Migration instance
+func DecodeFile(f string, assetFunc AssetFunc) (*Migration, error) {
+	return decodeFile(baseDir, f, assetFunc)
+}
+
+// decodeFile is like DecodeFile but looks up f in dir.
+func decodeFile(dir, f string, assetFunc AssetFunc) (*Migration, error) {
+	id, name, direction, err := parseFilename(f)
+	if direction == DirectionDown {
+		err = errors.New("down files are decoded along with their up file")
+	}
+
+	if err != nil {
+		return nil, &MigrationError{
+			Filename: f,
+			Phase:    PhaseDecode,
+			Err:      err,
+			kind:     ErrBadFilenameFormat,
+		}
+	}
+	single := direction == ""
+
+	m := new(Migration)
+	m.ID = id
+	m.Name = name
+	m.Filename = f
+
+	upFile := path.Join(dir, f)
+	upSQL, err := assetFunc(upFile)
+	if err != nil {
+		return nil, &MigrationError{
+			ID:        m.ID,
+			Filename:  upFile,
+			Direction: DirectionUp,
+			Phase:     PhaseDecode,
+			Err:       err,
+			kind:      ErrMigrationFailed,
+		}
+	}
+
+	if single {
+		m.Up, m.Down, err = decodeSections(string(upSQL))
+		if err != nil {
+			return nil, &MigrationError{
+				ID:       m.ID,
+				Filename: upFile,
+				Phase:    PhaseDecode,
+				Err:      err,
+				kind:     ErrBadMigrationFile,
+			}
+		}
+		m.Checksum = checksum(m.Up, m.Down)
+		return m, nil
+	}
+
+	downFile := path.Join(dir, strings.TrimSuffix(f, "_up.sql")+"_down.sql")
+	downSQL, err := assetFunc(downFile)
+	if err != nil {
+		return nil, &MigrationError{
+			ID:        m.ID,
+			Filename:  downFile,
+			Direction: DirectionDown,
+			Phase:     PhaseDecode,
+			Err:       err,
+			kind:      ErrMigrationFailed,
+		}
+	}
+
+	m.Up = string(upSQL[:])
+	m.Down = string(downSQL[:])
+	m.Checksum = checksum(m.Up, m.Down)
+	return m, nil
+}
+
+// parseFilename splits the name of a migration file into its version, name and
+// direction. File names should be formatted like so: version_name_up.sql or
+// version_name_down.sql, where version holds no underscores and is usually made
+// of digits, such as 0002 or a timestamp like 20261018093000, though v1 works
+// too, and name may hold underscores. Ex:
+// 0002_create_extension_citext_down.sql. Migrations with both halves in a
+// single file leave out the direction, which is returned empty. Ex:
+// 0003_create-table-users.sql
+func parseFilename(f string) (version, name string, direction Direction, err error) {
+	base, ok := strings.CutSuffix(f, ".sql")
+	if !ok {
+		return "", "", "", errors.New("missing .sql extension")
+	}
+
+	if rest, ok := strings.CutSuffix(base, "_up"); ok {
+		base, direction = rest, DirectionUp
+	} else if rest, ok := strings.CutSuffix(base, "_down"); ok {
+		base, direction = rest, DirectionDown
+	}
+
+	version, name, _ = strings.Cut(base, "_")
+	if version == "" {
+		return "", "", "", errors.New("missing version before the first underscore")
+	}
+
+	if name == "" {
+		return "", "", "", fmt.Errorf("missing name after version %s", version)
+	}
+	return version, name, direction, nil
+}
+
+// sectionMarker matches the comments starting the up and down sections of a
+// single file migration, like -- +migrate Up. They may be followed by the
+// notransaction option, like in sql-migrate.
+var sectionMarker = regexp.MustCompile(`(?i)^--\s*\+migrate\s+(up|down)(\s+.*)?$`)
+
+// decodeSections returns the up and down sections of a single file migration.
+// Only comments may precede the first section.
+func decodeSections(content string) (up, down string, err error) {
+	var sections [2]*strings.Builder
+	var section *strings.Builder
+	for n, line := range strings.SplitAfter(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		marker := sectionMarker.FindStringSubmatch(trimmed)
+		if marker == nil {
+			if section != nil {
+				section.WriteString(line)
+			} else if trimmed != "" && !strings.HasPrefix(trimmed, "--") {
+				return "", "", fmt.Errorf("line %d: statement outside of a -- +migrate Up or Down section", n+1)
+			}
+			continue
+		}
+
+		i := 0
+		if strings.EqualFold(marker[1], "down") {
+			i = 1
+		}
+
+		if sections[i] != nil {
+			return "", "", fmt.Errorf("line %d: duplicated %s section", n+1, trimmed)
+		}
+
+		section = new(strings.Builder)
+		if strings.EqualFold(strings.TrimSpace(marker[2]), "notransaction") {
+			section.WriteString(NoTransactionDirective + "\n")
+		}
+		sections[i] = section
+	}
+
+	if sections[0] == nil {
+		return "", "", errors.New("missing -- +migrate Up section")
+	}
+
+	if sections[1] == nil {
+		return "", "", errors.New("missing -- +migrate Down section")
+	}
+	return sections[0].String(), sections[1].String(), nil
 }
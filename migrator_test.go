@@ -2,5 +2,373 @@
 // License, version 2.0. If a copy of the MPL was not distributed with this
 // file, You can obtain one at http://mozilla.org/MPL/2.0/.
 
-//go:generate go-bindata -prefix "migrations/postgres" -nomemcopy -pkg migrations -o migrations/postgres.go migrations/postgres/...
 package migrator
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"testing"
+
+	"github.com/hooklift/assert"
+)
+
+func TestPlan(t *testing.T) {
+	files := []*Migration{
+		{ID: "0001", Up: "up 1"},
+		{ID: "0002", Up: "up 2"},
+		{ID: "0003", Up: "up 3"},
+	}
+
+	applied := []*Migration{
+		{ID: "0002", Status: "up", Down: "down 2"},
+		{ID: "0001", Status: "up", Down: "down 1"},
+	}
+
+	steps, err := plan(CommandMigrate, 1, files, applied)
+	assert.Ok(t, err)
+	assert.Equals(t, 1, len(steps))
+	assert.Equals(t, "0003", steps[0].ID)
+	assert.Equals(t, DirectionUp, steps[0].Direction)
+	assert.Equals(t, "up 3", steps[0].SQL)
+
+	steps, err = plan(CommandRollback, 5, files, applied)
+	assert.Ok(t, err)
+	assert.Equals(t, 2, len(steps))
+	assert.Equals(t, "0002", steps[0].ID)
+	assert.Equals(t, "0001", steps[1].ID)
+	assert.Equals(t, DirectionDown, steps[1].Direction)
+	assert.Equals(t, "down 1", steps[1].SQL)
+
+	steps, err = plan(CommandRedo, 1, files, applied)
+	assert.Ok(t, err)
+	assert.Equals(t, 3, len(steps))
+	assert.Equals(t, "0002", steps[0].ID)
+	assert.Equals(t, DirectionDown, steps[0].Direction)
+	assert.Equals(t, "0002", steps[1].ID)
+	assert.Equals(t, DirectionUp, steps[1].Direction)
+	assert.Equals(t, "0003", steps[2].ID)
+
+	steps, err = plan(CommandReset, 1, files, applied)
+	assert.Ok(t, err)
+	assert.Equals(t, 2, len(steps))
+	assert.Equals(t, "0002", steps[0].ID)
+	assert.Equals(t, "0001", steps[1].ID)
+	assert.Equals(t, DirectionDown, steps[1].Direction)
+
+	_, err = plan(Command("bogus"), 1, files, applied)
+	assert.Equals(t, ErrInvalidCommand, err)
+}
+
+func TestPlanRollbackTo(t *testing.T) {
+	files := []*Migration{
+		{ID: "0001", Up: "up 1"},
+		{ID: "0003", Up: "up 3"},
+	}
+
+	// The file of 0002 is gone, 0003 was taken down on its own.
+	applied := []*Migration{
+		{ID: "0004", Status: "up", Down: "down 4"},
+		{ID: "0003", Status: "down", Down: "down 3"},
+		{ID: "0002", Status: "up", Down: "down 2"},
+		{ID: "0001", Status: "up", Down: "down 1"},
+	}
+
+	steps, err := planRollbackTo("0001", files, applied)
+	assert.Ok(t, err)
+	assert.Equals(t, 2, len(steps))
+	assert.Equals(t, "0004", steps[0].ID)
+	assert.Equals(t, DirectionDown, steps[0].Direction)
+	assert.Equals(t, "down 4", steps[0].SQL)
+	assert.Equals(t, "0002", steps[1].ID)
+
+	steps, err = planRollbackTo("0002", files, applied)
+	assert.Ok(t, err)
+	assert.Equals(t, 1, len(steps))
+	assert.Equals(t, "0004", steps[0].ID)
+
+	steps, err = planRollbackTo("0004", files, applied)
+	assert.Ok(t, err)
+	assert.Equals(t, 0, len(steps))
+
+	_, err = planRollbackTo("0005", files, applied)
+	assert.Cond(t, errors.Is(err, ErrMigrationNotFound), "expected ErrMigrationNotFound, got %v", err)
+}
+
+func TestPlanTo(t *testing.T) {
+	files := []*Migration{
+		{ID: "0001", Up: "up 1"},
+		{ID: "0002", Up: "up 2"},
+		{ID: "0003", Up: "up 3"},
+		{ID: "0004", Up: "up 4"},
+	}
+
+	// 0002 was taken down on its own.
+	applied := []*Migration{
+		{ID: "0004", Status: "up", Down: "down 4"},
+		{ID: "0003", Status: "up", Down: "down 3"},
+		{ID: "0002", Status: "down", Down: "down 2"},
+		{ID: "0001", Status: "up", Down: "down 1"},
+	}
+
+	steps, err := planTo("0002", files, applied)
+	assert.Ok(t, err)
+	assert.Equals(t, 3, len(steps))
+	assert.Equals(t, "0004", steps[0].ID)
+	assert.Equals(t, DirectionDown, steps[0].Direction)
+	assert.Equals(t, "down 4", steps[0].SQL)
+	assert.Equals(t, "0003", steps[1].ID)
+	assert.Equals(t, DirectionDown, steps[1].Direction)
+	assert.Equals(t, "0002", steps[2].ID)
+	assert.Equals(t, DirectionUp, steps[2].Direction)
+	assert.Equals(t, "up 2", steps[2].SQL)
+
+	steps, err = planTo("0003", files, applied[2:])
+	assert.Ok(t, err)
+	assert.Equals(t, 2, len(steps))
+	assert.Equals(t, "0002", steps[0].ID)
+	assert.Equals(t, "0003", steps[1].ID)
+	assert.Equals(t, DirectionUp, steps[1].Direction)
+
+	steps, err = planTo("0001", files, applied[3:])
+	assert.Ok(t, err)
+	assert.Equals(t, 0, len(steps))
+
+	_, err = planTo("0005", files, applied)
+	assert.Cond(t, errors.Is(err, ErrMigrationNotFound), "expected ErrMigrationNotFound, got %v", err)
+}
+
+func TestVerifyDrift(t *testing.T) {
+	files := []*Migration{
+		{ID: "0001", Checksum: checksum("up 1", "down 1")},
+		{ID: "0002", Checksum: checksum("up 2 edited", "down 2")},
+	}
+
+	applied := []*Migration{
+		{ID: "0003", Status: "up", Checksum: checksum("up 3", "down 3")},
+		{ID: "0002", Status: "up", Checksum: checksum("up 2", "down 2")},
+		{ID: "0001", Status: "up", Checksum: checksum("up 1", "down 1")},
+	}
+
+	drift := verify(files, applied)
+	assert.Equals(t, 2, len(drift))
+	assert.Equals(t, "0003", drift[0].ID)
+	assert.Equals(t, "", drift[0].Checksum)
+	assert.Equals(t, "0002", drift[1].ID)
+	assert.Equals(t, files[1].Checksum, drift[1].Checksum)
+
+	applied[0].Status = "down"
+	applied[1].Status = "down"
+	assert.Equals(t, 0, len(verify(files, applied)))
+}
+
+func TestLockKey(t *testing.T) {
+	assert.Equals(t, DefaultLockKey, newConfig(nil).lockKey)
+	assert.Equals(t, DefaultLockKey, newConfig([]Option{WithSchema("public")}).lockKey)
+
+	other := newConfig([]Option{WithTableName("other_migrations")}).lockKey
+	assert.Cond(t, other != DefaultLockKey, "expected other_migrations to have its own lock key")
+	assert.Equals(t, other, newConfig([]Option{WithSchema("public"), WithTableName("other_migrations")}).lockKey)
+	assert.Equals(t, int64(42), newConfig([]Option{WithTableName("other_migrations"), WithLockKey(42)}).lockKey)
+}
+
+func TestMigrationError(t *testing.T) {
+	_, err := DecodeFile("0001-bad-name.sql", nil)
+	assert.Cond(t, errors.Is(err, ErrBadFilenameFormat), "expected ErrBadFilenameFormat, got %v", err)
+
+	_, err = DecodeFile("0001_missing_up.sql", func(string) ([]byte, error) {
+		return nil, fs.ErrNotExist
+	})
+	assert.Cond(t, errors.Is(err, ErrMigrationFailed), "expected ErrMigrationFailed, got %v", err)
+	assert.Cond(t, errors.Is(err, fs.ErrNotExist), "expected fs.ErrNotExist, got %v", err)
+
+	var merr *MigrationError
+	assert.Cond(t, errors.As(err, &merr), "expected *MigrationError, got %T", err)
+	assert.Equals(t, "0001", merr.ID)
+	assert.Equals(t, PhaseDecode, merr.Phase)
+	assert.Equals(t, DirectionUp, merr.Direction)
+}
+
+func TestParseFilename(t *testing.T) {
+	id, name, dir, err := parseFilename("0008_add_user_email_up.sql")
+	assert.Ok(t, err)
+	assert.Equals(t, "0008", id)
+	assert.Equals(t, "add_user_email", name)
+	assert.Equals(t, DirectionUp, dir)
+
+	id, name, dir, err = parseFilename("20261018093000_create-users_down.sql")
+	assert.Ok(t, err)
+	assert.Equals(t, "20261018093000", id)
+	assert.Equals(t, "create-users", name)
+	assert.Equals(t, DirectionDown, dir)
+
+	id, name, dir, err = parseFilename("0003_create_users.sql")
+	assert.Ok(t, err)
+	assert.Equals(t, "0003", id)
+	assert.Equals(t, "create_users", name)
+	assert.Equals(t, Direction(""), dir)
+
+	id, name, dir, err = parseFilename("v1_users_up.sql")
+	assert.Ok(t, err)
+	assert.Equals(t, "v1", id)
+	assert.Equals(t, "users", name)
+	assert.Equals(t, DirectionUp, dir)
+
+	for f, reason := range map[string]string{
+		"0001_users_up.txt":  "missing .sql extension",
+		"_users_up.sql":      "missing version before the first underscore",
+		"0001_up.sql":        "missing name after version 0001",
+		"0001_users.sql.bak": "missing .sql extension",
+		"0001.sql":           "missing name after version 0001",
+	} {
+		_, err := DecodeFile(f, nil)
+		assert.Cond(t, errors.Is(err, ErrBadFilenameFormat), "%s: expected ErrBadFilenameFormat, got %v", f, err)
+		assert.Equals(t, fmt.Sprintf("bad-filename-format: migration file %q failed to decode: %s", f, reason), err.Error())
+	}
+}
+
+func TestSingleFile(t *testing.T) {
+	files := map[string]string{
+		"0001_users.sql": `-- Creates the users table.
+-- +migrate Up
+create table users (id int);
+
+-- +migrate Down
+drop table users;
+`,
+		"0002_index.sql": `-- +migrate Down
+drop index users_id;
+-- +migrate up notransaction
+create index concurrently users_id on users (id);
+`,
+		"0003_missing.sql":   "-- +migrate Up\ncreate table t (c int);\n",
+		"0004_stray.sql":     "create table t (c int);\n-- +migrate Up\n-- +migrate Down\n",
+		"0005_twice.sql":     "-- +migrate Up\n-- +migrate Down\n-- +migrate Up\n",
+		"0006_paired_up.sql": "create table p (c int);",
+	}
+	assetFunc := func(name string) ([]byte, error) {
+		if f, ok := files[name]; ok {
+			return []byte(f), nil
+		}
+		return nil, fs.ErrNotExist
+	}
+
+	m, err := decodeFile(".", "0001_users.sql", assetFunc)
+	assert.Ok(t, err)
+	assert.Equals(t, "0001", m.ID)
+	assert.Equals(t, "users", m.Name)
+	assert.Equals(t, "0001_users.sql", m.Filename)
+	assert.Equals(t, "create table users (id int);\n\n", m.Up)
+	assert.Equals(t, "drop table users;\n", m.Down)
+	assert.Equals(t, checksum(m.Up, m.Down), m.Checksum)
+
+	m, err = decodeFile(".", "0002_index.sql", assetFunc)
+	assert.Ok(t, err)
+	assert.Cond(t, noTransaction(m.Up), "expected up section to opt out of transactions")
+	assert.Cond(t, !noTransaction(m.Down), "expected down section to run in a transaction")
+
+	for _, f := range []string{"0003_missing.sql", "0004_stray.sql", "0005_twice.sql"} {
+		_, err = decodeFile(".", f, assetFunc)
+		assert.Cond(t, errors.Is(err, ErrBadMigrationFile), "%s: expected ErrBadMigrationFile, got %v", f, err)
+	}
+
+	// A paired migration looks for its down file.
+	_, err = decodeFile(".", "0006_paired_up.sql", assetFunc)
+	var merr *MigrationError
+	assert.Cond(t, errors.As(err, &merr), "expected *MigrationError, got %T", err)
+	assert.Equals(t, "0006_paired_down.sql", merr.Filename)
+	assert.Equals(t, DirectionDown, merr.Direction)
+}
+
+func TestValidate(t *testing.T) {
+	files := map[string]string{
+		"0001_users_up.sql":      "create table users (id int);",
+		"0001_users_down.sql":    "drop table users;",
+		"0002_orphan_up.sql":     "create table orphans (id int);",
+		"0003_widows_down.sql":   "drop table widows;",
+		"0004_empty_up.sql":      " \n",
+		"0004_empty_down.sql":    "-- nothing to undo",
+		"0005_one.sql":           "-- +migrate Up\ncreate table one (id int);\n-- +migrate Down\n",
+		"0005_two_up.sql":        "create table two (id int);",
+		"0005_two_down.sql":      "drop table two;",
+		"0006_backfill.sql":      "-- +migrate Up\nupdate users set id = id;\n-- +migrate Down\nselect 1;",
+		"2_late_up.sql":          "create table late (id int);",
+		"2_late_down.sql":        "drop table late;",
+		"0008a_hotfix_up.sql":    "create table hotfix (id int);",
+		"0008a_hotfix_down.sql":  "drop table hotfix;",
+		"README.md":              "# Migrations",
+		"0007_bad-sections.sql":  "create table t (c int);",
+		"0008_unreadable_up.sql": "",
+	}
+	paths := make([]string, 0, len(files))
+	for f := range files {
+		paths = append(paths, f)
+	}
+	sort.Strings(paths)
+
+	assetFunc := func(name string) ([]byte, error) {
+		if name == "0008_unreadable_up.sql" {
+			return nil, fs.ErrPermission
+		}
+		return []byte(files[name]), nil
+	}
+
+	goMigrations := map[string]*Migration{"0006": {ID: "0006"}}
+	err := validate(".", paths, assetFunc, goMigrations)
+
+	var problems []string
+	for _, err := range err.(interface{ Unwrap() []error }).Unwrap() {
+		problems = append(problems, err.Error())
+	}
+
+	assert.Equals(t, []string{
+		`empty-migration: migration file "0004_empty_up.sql" failed to validate up: file is empty`,
+		`empty-migration: migration file "0005_one.sql" failed to validate down: down section is empty`,
+		`bad-migration-file: migration file "0007_bad-sections.sql" failed to validate: line 1: statement outside of a -- +migrate Up or Down section`,
+		`migration-failed: migration file "0008_unreadable_up.sql" failed to validate up: permission denied`,
+		`bad-filename-format: migration file "README.md" failed to validate: missing .sql extension`,
+		`unpaired-migration-file: migration file "0002_orphan_up.sql" failed to validate down: missing 0002_orphan_down.sql`,
+		`unpaired-migration-file: migration file "0003_widows_down.sql" failed to validate up: missing 0003_widows_up.sql`,
+		`unpaired-migration-file: migration file "0008_unreadable_up.sql" failed to validate down: missing 0008_unreadable_down.sql`,
+		`duplicate-migration-id: migration "0005" failed to validate: used by 0005_one.sql, 0005_two`,
+		`duplicate-migration-id: migration "0006" failed to validate: used by 0006_backfill.sql, a go migration`,
+		`ambiguous-migration-order: migration "2" failed to validate: sorts after 0008 but is numerically lower, pad versions to the same width`,
+	}, problems)
+
+	assert.Cond(t, errors.Is(err, ErrUnpairedMigration), "expected ErrUnpairedMigration, got %v", err)
+	assert.Cond(t, errors.Is(err, ErrDuplicateMigration), "expected ErrDuplicateMigration, got %v", err)
+
+	err = validate(".", []string{"0001_users_down.sql", "0001_users_up.sql", "0006_backfill.sql"}, assetFunc, nil)
+	assert.Ok(t, err)
+
+	goMigrations = map[string]*Migration{"0001a": {}, "0002_backfill": {}, "3": {}}
+	err = validate(".", []string{"0001_users_down.sql", "0001_users_up.sql", "0006_backfill.sql"}, assetFunc, goMigrations)
+	assert.Cond(t, errors.Is(err, ErrBadMigrationID), "expected ErrBadMigrationID, got %v", err)
+	assert.Equals(t, `bad-migration-id: migration "0002_backfill" failed to validate: must not be empty or contain underscores`+"\n"+
+		`ambiguous-migration-order: migration "3" failed to validate: sorts after 0006 but is numerically lower, pad versions to the same width`, err.Error())
+}
+
+func TestNoTransactionDirective(t *testing.T) {
+	assert.Cond(t, noTransaction("-- migrator:no-transaction\ncreate index concurrently i on t (c);"), "expected directive to be found")
+	assert.Cond(t, noTransaction("\n-- adds an index\n--   migrator:no-transaction\ncreate index concurrently i on t (c);"), "expected directive to be found after other comments")
+	assert.Cond(t, !noTransaction("create table t (c int);\n-- migrator:no-transaction"), "expected directive to be ignored after statements")
+	assert.Cond(t, !noTransaction("create table t (c int);"), "expected no directive")
+}
+
+func TestSplitStatements(t *testing.T) {
+	stmts := splitStatements(`
+		-- migrator:no-transaction
+		create index concurrently i on t (c);
+		insert into t (c) values ('a;b'), ("c;d"); /* ; */
+		create function f() returns text as $body$ select ';' $body$ language sql;
+		-- trailing; comment
+	`)
+
+	assert.Equals(t, 3, len(stmts))
+	assert.Equals(t, "-- migrator:no-transaction\n\t\tcreate index concurrently i on t (c)", stmts[0])
+	assert.Equals(t, `insert into t (c) values ('a;b'), ("c;d")`, stmts[1])
+	assert.Equals(t, "/* ; */\n\t\tcreate function f() returns text as $body$ select ';' $body$ language sql", stmts[2])
+
+	assert.Equals(t, []string{"select 'unterminated;"}, splitStatements("select 'unterminated;"))
+}
@@ -0,0 +1,222 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+//go:build mysql
+// +build mysql
+
+package migrator
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	// Loads MySQL driver
+	_ "github.com/go-sql-driver/mysql"
+)
+
+func init() {
+	Register(MySQL, NewMySQL)
+}
+
+// mysqlDialect implements Dialect for MySQL 8 and MariaDB.
+//
+// MySQL commits implicitly before and after most DDL statements, so a migration
+// can't be rolled back as a whole the way it is in Postgres. Migrations run
+// outside of a transaction and are recorded in the migrations table once they
+// succeed. When a migration fails half way through, the statements before the
+// failing one stay applied and the migration is not recorded, so the database
+// has to be fixed by hand before retrying it.
+//
+// The connection string must enable multiStatements, since migration files
+// usually contain several statements, and parseTime.
+type mysqlDialect struct{}
+
+// NewMySQL creates MySQL driver
+func NewMySQL(db *sql.DB, c Config) (Driver, error) {
+	return NewSQLDriver(db, mysqlDialect{}, c), nil
+}
+
+// Quote returns name quoted as an identifier and qualified with database if
+// given.
+func (mysqlDialect) Quote(database, name string) string {
+	quote := func(s string) string {
+		return "`" + strings.Replace(s, "`", "``", -1) + "`"
+	}
+
+	if database == "" {
+		return quote(name)
+	}
+	return quote(database) + "." + quote(name)
+}
+
+// Placeholder returns ?.
+func (mysqlDialect) Placeholder(n int) string {
+	return "?"
+}
+
+// CreateTable returns the statement creating the migrations table.
+func (mysqlDialect) CreateTable(table, statusCheck string) string {
+	// CHECK constraints are enforced starting with MySQL 8.0.16 and MariaDB
+	// 10.2, older versions parse and ignore them.
+	return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			-- migration identifier as found in migration file name.
+			id            VARCHAR(255)  NOT NULL,
+			-- migration name as found in migration file name.
+			name          VARCHAR(255)  NOT NULL,
+			-- migration file name.
+			filename      VARCHAR(1024) NOT NULL,
+			-- migration sql content as found in Up migration file.
+			up            LONGTEXT      NOT NULL,
+			-- migration sql content as found in Down migration file.
+			down          LONGTEXT      NOT NULL,
+			-- status of this migration
+			status        VARCHAR(4),
+			-- checksum of the up and down sql.
+			checksum      CHAR(64),
+			-- whether the migration was applied after one with a greater id.
+			out_of_order  BOOLEAN       NOT NULL DEFAULT FALSE,
+			-- order in which the migration was last applied.
+			sequence      BIGINT,
+			-- milliseconds it took to last apply or revert the migration.
+			duration_ms   BIGINT,
+			-- database user, host and application version that last applied
+			-- or reverted the migration.
+			db_user       VARCHAR(255),
+			hostname      VARCHAR(255),
+			app_version   VARCHAR(255),
+			-- timestamp of when the migration was created.
+			created_at    TIMESTAMP(6)  NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+			-- timestamp of when the migration was updated.
+			updated_at    TIMESTAMP(6)  NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+
+			PRIMARY KEY (id),
+			CONSTRAINT %s CHECK (status IN ('up', 'down'))
+		) DEFAULT CHARACTER SET utf8mb4 COLLATE utf8mb4_bin
+	`, table, statusCheck)
+}
+
+// CreateHistoryTable returns the statement creating the history table.
+func (mysqlDialect) CreateHistoryTable(table string) string {
+	return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			-- order in which transitions happened.
+			id            BIGINT        NOT NULL AUTO_INCREMENT,
+			-- id, name and file name of the migration.
+			migration_id  VARCHAR(255)  NOT NULL,
+			name          VARCHAR(255)  NOT NULL,
+			filename      VARCHAR(1024) NOT NULL,
+			-- whether the migration was applied or reverted.
+			direction     VARCHAR(4)    NOT NULL,
+			-- checksum of the up and down sql.
+			checksum      CHAR(64),
+			-- whether the migration was applied after one with a greater id.
+			out_of_order  BOOLEAN       NOT NULL DEFAULT FALSE,
+			-- milliseconds it took to apply or revert the migration.
+			duration_ms   BIGINT,
+			-- database user, host and application version that applied or
+			-- reverted the migration.
+			db_user       VARCHAR(255),
+			hostname      VARCHAR(255),
+			app_version   VARCHAR(255),
+			-- timestamp of the transition.
+			created_at    TIMESTAMP(6)  NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+
+			PRIMARY KEY (id)
+		) DEFAULT CHARACTER SET utf8mb4 COLLATE utf8mb4_bin
+	`, table)
+}
+
+// CurrentUser returns CURRENT_USER().
+func (mysqlDialect) CurrentUser() string {
+	return "CURRENT_USER()"
+}
+
+// AddColumn adds the column with the type it has in CreateTable.
+func (mysqlDialect) AddColumn(table, column string) string {
+	types := map[string]string{
+		"out_of_order": "BOOLEAN NOT NULL DEFAULT FALSE",
+		"sequence":     "BIGINT",
+		"duration_ms":  "BIGINT",
+		"db_user":      "VARCHAR(255)",
+		"hostname":     "VARCHAR(255)",
+		"app_version":  "VARCHAR(255)",
+	}
+	return fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, column, types[column])
+}
+
+// Upsert records a migration with ON DUPLICATE KEY UPDATE.
+func (mysqlDialect) Upsert(table string) string {
+	return fmt.Sprintf(`
+		INSERT INTO %s (
+			id, name, filename, up, down, status, checksum, out_of_order, sequence,
+			duration_ms, hostname, app_version, db_user
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_USER())
+		ON DUPLICATE KEY UPDATE
+			status = VALUES(status), up = VALUES(up), down = VALUES(down),
+			checksum = VALUES(checksum), out_of_order = VALUES(out_of_order),
+			sequence = VALUES(sequence), duration_ms = VALUES(duration_ms),
+			hostname = VALUES(hostname), app_version = VALUES(app_version),
+			db_user = VALUES(db_user), updated_at = CURRENT_TIMESTAMP(6)
+	`, table)
+}
+
+// TryLock takes a named lock, named after the key.
+func (mysqlDialect) TryLock() string {
+	return `SELECT GET_LOCK(CONCAT('migrator:', ?), 0)`
+}
+
+// Unlock releases the named lock taken by TryLock.
+func (mysqlDialect) Unlock() string {
+	return `SELECT RELEASE_LOCK(CONCAT('migrator:', ?))`
+}
+
+// Transactional returns false, MySQL commits implicitly on DDL statements.
+func (mysqlDialect) Transactional() bool {
+	return false
+}
+
+// Session sets the statement and lock timeouts of the session.
+func (mysqlDialect) Session(ctx context.Context, conn Queryer, c Config) ([]string, error) {
+	var set, reset []string
+	if c.StatementTimeout > 0 {
+		// Only bounds SELECT statements, so the SQL of a migration is
+		// bounded through its context too, which kills the connection.
+		set = append(set, fmt.Sprintf("max_execution_time = %d", c.StatementTimeout.Milliseconds()))
+		reset = append(reset, "max_execution_time = DEFAULT")
+	}
+
+	if c.LockTimeout > 0 {
+		// Both settings take whole seconds, rounded up so small timeouts
+		// don't turn into no wait at all.
+		secs := int64((c.LockTimeout + time.Second - 1) / time.Second)
+		set = append(set, fmt.Sprintf("lock_wait_timeout = %d, innodb_lock_wait_timeout = %d", secs, secs))
+		reset = append(reset, "lock_wait_timeout = DEFAULT, innodb_lock_wait_timeout = DEFAULT")
+	}
+
+	if len(set) > 0 {
+		if _, err := conn.ExecContext(ctx, "SET SESSION "+strings.Join(set, ", ")); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(reset) == 0 {
+		return nil, nil
+	}
+	return []string{"SET SESSION " + strings.Join(reset, ", ")}, nil
+}
+
+// BoundsStatements returns false, max_execution_time only applies to SELECT
+// statements.
+func (mysqlDialect) BoundsStatements() bool {
+	return false
+}
+
+// Check does nothing, MySQL validates changes as they are made.
+func (mysqlDialect) Check(ctx context.Context, q Queryer) error {
+	return nil
+}
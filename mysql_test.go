@@ -0,0 +1,96 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+//go:build mysql
+// +build mysql
+
+package migrator
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/c4milo/migrator/migrations"
+	"github.com/hooklift/assert"
+)
+
+// openMySQL connects to the database in $MIGRATOR_MYSQL_DSN, which must enable
+// multiStatements and parseTime, skipping the test if it isn't set.
+func openMySQL(t *testing.T) *sql.DB {
+	dsn := os.Getenv("MIGRATOR_MYSQL_DSN")
+	if dsn == "" {
+		t.Skip("MIGRATOR_MYSQL_DSN is not set")
+	}
+
+	mdb, err := sql.Open("mysql", dsn)
+	assert.Ok(t, err)
+	return mdb
+}
+
+func TestMySQLMigrate(t *testing.T) {
+	mdb := openMySQL(t)
+	defer mdb.Close()
+
+	m, err := NewMigratorFS(mdb, MySQL, migrations.FS, "mysql")
+	assert.Ok(t, err)
+
+	err = m.Migrate()
+	assert.Ok(t, err)
+
+	ms, err := m.Migrations()
+	assert.Ok(t, err)
+	assert.Equals(t, 2, len(ms))
+	assert.Equals(t, "0002", ms[0].ID)
+	assert.Equals(t, "up", ms[0].Status)
+
+	err = m.Rollback(2)
+	assert.Ok(t, err)
+
+	row := mdb.QueryRow("select count(*) from information_schema.tables where table_schema = database() and table_name = 'accounts'")
+	var tm int
+	row.Scan(&tm)
+	assert.Equals(t, 0, tm)
+
+	err = m.Redo()
+	assert.Ok(t, err)
+
+	err = m.Down("0002")
+	assert.Ok(t, err)
+
+	err = m.Up("0002")
+	assert.Ok(t, err)
+
+	drift, err := m.Verify()
+	assert.Ok(t, err)
+	assert.Equals(t, 0, len(drift))
+
+	err = m.Rollback(2)
+	assert.Ok(t, err)
+}
+
+func TestMySQLLockTimeout(t *testing.T) {
+	mdb := openMySQL(t)
+	defer mdb.Close()
+
+	m, err := NewMigratorFS(mdb, MySQL, migrations.FS, "mysql", WithLockWait(500*time.Millisecond))
+	assert.Ok(t, err)
+
+	conn, err := mdb.Conn(context.Background())
+	assert.Ok(t, err)
+	defer conn.Close()
+
+	_, err = conn.ExecContext(context.Background(), "select get_lock(?, 0)", fmt.Sprintf("migrator:%d", DefaultLockKey))
+	assert.Ok(t, err)
+
+	err = m.Migrate()
+	assert.Cond(t, errors.Is(err, ErrLockTimeout), "expected ErrLockTimeout, got %v", err)
+
+	_, err = conn.ExecContext(context.Background(), "select release_lock(?)", fmt.Sprintf("migrator:%d", DefaultLockKey))
+	assert.Ok(t, err)
+}
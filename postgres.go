@@ -2,403 +2,277 @@
 // License, version 2.0. If a copy of the MPL was not distributed with this
 // file, You can obtain one at http://mozilla.org/MPL/2.0/.
 
+//go:build postgres
 // +build postgres
 
 package migrator
 
 import (
+	"context"
 	"database/sql"
-	"errors"
 	"fmt"
-	"log"
-	"runtime/debug"
-	"strings"
-	"sync"
-	"time"
-
-	// Loads Postgres driver
-	_ "github.com/lib/pq"
-)
 
-var (
-	// ErrCreatingTable is returned when creating the migration table fails.
-	ErrCreatingTable = errors.New("error-creating-migration-table")
-	// ErrRegisteringMigration is returned when inserting an entry into the migration table fails.
-	ErrRegisteringMigration = errors.New("error-registering-migration")
-	// ErrGettingMigrations is returned when querying the migrations table fails.
-	ErrGettingMigrations = errors.New("error-getting-migrations")
-	// ErrRollbackFailed is returned if rolling back an actual migration fails.
-	ErrRollbackFailed = errors.New("error-rolling-back-migrations")
-	// ErrUpdatingMigration is returned when updating migration metadata fails.
-	ErrUpdatingMigration = errors.New("error-updating-migration-metadata")
-	// ErrRedoFailed is returned if redoing a migration fails for some reason.
-	ErrRedoFailed = errors.New("redo-error")
-	// ErrMigrationNotFound is returned when a migration was not found in the internal
-	// migrations table.
-	ErrMigrationNotFound = errors.New("migration-not-found")
-	// ErrMigrationIDrequired is returned if a migration is attempted to be created without an ID.
-	ErrMigrationIDrequired = errors.New("migration-id-required")
-	// ErrDownFailed is returned when taking down a migration fails.
-	ErrDownFailed = errors.New("migration-down-failed")
+	"github.com/lib/pq"
 )
 
-type postgres struct {
-	sync.Mutex
-	db           *sql.DB
-	paths        []string
-	assetFunc    AssetFunc
-	assetDirFunc AssetDirFunc
+func init() {
+	Register(Postgres, NewPostgresDriver)
 }
 
-// NewPostgres creates Postgres migrator
-func NewPostgres(db *sql.DB, paths []string, assetFunc AssetFunc) (*postgres, error) {
-	return &postgres{
-		db:        db,
-		paths:     paths,
-		assetFunc: assetFunc,
-	}, nil
+// postgres implements Driver for Postgres. Migrations run in a transaction
+// along with their record, so a failing migration leaves no trace.
+type postgres struct {
+	*sqlDriver
 }
 
-// Init initializes the migration table.
-func (p *postgres) Init() error {
-	p.Lock()
-	defer p.Unlock()
-	_, err := p.db.Exec(`
-		-- creates an enum type for migration status types
-		do $$
-		begin
-			if not exists (select 1 from pg_type where typname = 'migration_status_type') then
-				create type migration_status_type as enum ('up', 'down');
-			end if;
-		end$$;
-
-		-- creates citext extension
-		create extension if not exists citext;
-
-		-- creates table schema_migrations
-		create table if not exists schema_migrations (
-			-- migration identifier as found in migration file name.
-			id            citext not null,
-			-- migration name as found in migration file name.
-			name          text   not null,
-			-- migration file name.
-			filename      text   not null,
-			-- migration sql content as found in Up migration file.
-			up            text   not null,
-			-- migration sql content as found in Down migration file.
-			down          text   not null,
-			-- status of this migration
-			status        migration_status_type,
-			-- timestamp of when the migration was created.
-			created_at    timestamptz not null default current_timestamp,
-			-- timestamp of when the migration was updated.
-			updated_at    timestamptz not null,
-
-			primary key (id)
-		);
-	`)
-
-	if err != nil {
-		debug.PrintStack()
-		log.Printf("[ERROR] %#v", err)
-		return ErrCreatingTable
-	}
-
-	return nil
+// NewPostgresDriver creates Postgres driver
+func NewPostgresDriver(db *sql.DB, c Config) (Driver, error) {
+	return &postgres{newSQLDriver(db, postgresDialect{}, c)}, nil
 }
 
-// Up re-applies the specific migration ID only if the migration exists and has
-// status "down"
-func (p *postgres) Up(id string) error {
-	ms, err := p.Migrations(id)
-	exists := len(ms) > 0
-	if !exists {
-		return ErrMigrationNotFound
-	}
-
-	m := ms[0]
-
-	if m.Status == "up" {
-		return nil
-	}
-
-	tx, err := p.db.Begin()
-	if err != nil {
-		debug.PrintStack()
-		log.Printf("[ERROR] %#v", err)
-		return ErrMigrationFailed
-	}
+// NewPostgres creates Postgres migrator for the migration files at paths.
+//
+// Deprecated: Use NewMigrator or NewMigratorFS with the Postgres database type.
+func NewPostgres(db *sql.DB, paths []string, assetFunc AssetFunc) (Migrator, error) {
+	return NewMigrator(db, Postgres, assetFunc, func(string) ([]string, error) {
+		return paths, nil
+	})
+}
 
-	if _, err := tx.Exec(`DELETE FROM schema_migrations WHERE id = $1`, id); err != nil {
-		tx.Rollback()
+// Bootstrap creates the migration table and upgrades the ones created by
+// previous versions of this package.
+func (p *postgres) Bootstrap(ctx context.Context) error {
+	if err := p.sqlDriver.Bootstrap(ctx); err != nil {
 		return err
 	}
-
-	newM, err := DecodeFile(m.Filename, p.assetFunc)
-	if err != nil {
-		debug.PrintStack()
-		log.Printf("[ERROR] %#v", err)
-		tx.Rollback()
-		return ErrMigrationFailed
-	}
-
-	return p.migrate(newM, tx)
+	return p.upgrade(ctx)
 }
 
-// Down takes down the migration identified by the given ID.
-func (p *postgres) Down(id string) error {
-	if id == "" {
-		return ErrMigrationIDrequired
-	}
-
-	migrations, err := p.db.Query(`
-		SELECT id, down FROM schema_migrations
-		WHERE status = 'up'
-		AND   id = $1`, id)
+// upgrade brings migrations tables created by previous versions of this package
+// up to date. Those used the citext extension for the id column and an enum type
+// for the status column, which are converted in place to plain text, and they
+// lacked the checksum column. It does nothing on up to date tables, so owning
+// the table is only required when there is something to upgrade.
+func (p *postgres) upgrade(ctx context.Context) error {
+	schema := sql.NullString{String: p.config.Schema, Valid: p.config.Schema != ""}
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT column_name, udt_name FROM information_schema.columns
+		WHERE  table_schema = coalesce($1, current_schema())
+		AND    table_name = $2`, schema, p.config.TableName)
 	if err != nil {
-		log.Printf("[ERROR] %#v", err)
-		return ErrDownFailed
+		return err
 	}
-	defer migrations.Close()
-
-	return p.rollback(migrations)
-}
-
-func (p *postgres) rollback(migrations *sql.Rows) error {
-	for migrations.Next() {
-		var id, downSQL string
-		if err := migrations.Scan(&id, &downSQL); err != nil {
-			log.Printf("[ERROR] %#v", err)
-			return ErrRollbackFailed
-		}
-
-		tx, err := p.db.Begin()
-		if err != nil {
-			log.Printf("[ERROR] %#v", err)
-			return ErrRollbackFailed
-		}
-
-		if _, err = tx.Exec(downSQL); err != nil {
-			log.Printf("[ERROR] %#v", err)
-			log.Printf("[ERROR] Down query: %s", downSQL)
-			tx.Rollback()
-			return ErrRollbackFailed
-		}
-
-		if _, err = tx.Exec(`
-			UPDATE schema_migrations
-			SET    status = $1
-			WHERE  id = $2
-		`, "down", id); err != nil {
-			log.Printf("[ERROR] id=%s status=%s err=%#v", id, "down", err)
-			tx.Rollback()
-			return ErrRollbackFailed
-		}
+	defer rows.Close()
 
-		if err := tx.Commit(); err != nil {
-			log.Printf("[ERROR] %#v", err)
-			tx.Rollback()
-			return ErrRollbackFailed
+	columns := make(map[string]string)
+	for rows.Next() {
+		var name, udt string
+		if err := rows.Scan(&name, &udt); err != nil {
+			return err
 		}
+		columns[name] = udt
 	}
 
-	if err := migrations.Err(); err != nil {
-		log.Printf("[ERROR] %#v", err)
-		return ErrRollbackFailed
+	if err := rows.Err(); err != nil {
+		return err
 	}
-	return nil
-}
 
-// Redo re-runs a given number of latests migrations.
-func (p *postgres) Redo(steps ...uint) error {
-	n := uint(1)
-	if len(steps) > 0 {
-		n = steps[0]
+	var stmts []string
+	if udt := columns["id"]; udt != "text" {
+		stmts = append(stmts, fmt.Sprintf(`ALTER TABLE %s ALTER COLUMN id TYPE text`, p.table))
 	}
 
-	l := len(p.paths)
-	numMigrations := uint(l / 2)
+	if udt := columns["status"]; udt != "text" {
+		stmts = append(stmts,
+			fmt.Sprintf(`ALTER TABLE %s ALTER COLUMN status TYPE text USING status::text`, p.table),
+			fmt.Sprintf(`ALTER TABLE %s ADD CONSTRAINT %s CHECK (status IN ('up', 'down'))`, p.table, p.statusCheck),
+		)
+	}
 
-	if n > numMigrations {
-		n = numMigrations
+	if _, ok := columns["checksum"]; !ok {
+		// Null for migrations applied before checksums were recorded.
+		stmts = append(stmts, fmt.Sprintf(`ALTER TABLE %s ADD COLUMN checksum text`, p.table))
 	}
 
-	if err := p.Rollback(n); err != nil {
-		return err
+	if len(stmts) == 0 {
+		return nil
 	}
 
-	if err := p.Migrate(); err != nil {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
 		return err
 	}
 
-	return nil
+	for _, stmt := range stmts {
+		p.config.Logger.Info("upgrading migrations table", "table", p.table, "statement", stmt)
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
 }
 
-// Rollback removes a given number of latests migrations.
-func (p *postgres) Rollback(steps ...uint) error {
-	n := uint(1)
-	if len(steps) > 0 {
-		n = steps[0]
-	}
+// postgresDialect implements Dialect for Postgres.
+type postgresDialect struct{}
 
-	migrations, err := p.db.Query(`
-		SELECT id, down FROM schema_migrations
-		WHERE status = 'up'
-		ORDER BY id DESC LIMIT $1`, n)
-	if err != nil {
-		log.Printf("[ERROR] %#v", err)
-		return ErrRollbackFailed
+// Quote returns name quoted as an identifier and qualified with schema if
+// given.
+func (postgresDialect) Quote(schema, name string) string {
+	if schema == "" {
+		return pq.QuoteIdentifier(name)
 	}
-	defer migrations.Close()
-
-	return p.rollback(migrations)
+	return pq.QuoteIdentifier(schema) + "." + pq.QuoteIdentifier(name)
 }
 
-func (p *postgres) Migrate() error {
-	for i := 0; i < len(p.paths); i++ {
-		f := p.paths[i]
-
-		if strings.HasSuffix(f, "down.sql") {
-			continue
-		}
+// Placeholder returns $n.
+func (postgresDialect) Placeholder(n int) string {
+	return fmt.Sprintf("$%d", n)
+}
 
-		m, err := DecodeFile(f, p.assetFunc)
-		if err != nil {
-			return err
-		}
+// CreateTable only requires the CREATE privilege on the schema, no extensions
+// or types are created.
+func (postgresDialect) CreateTable(table, statusCheck string) string {
+	return fmt.Sprintf(`
+		create table if not exists %s (
+			-- migration identifier as found in migration file name.
+			id            text not null,
+			-- migration name as found in migration file name.
+			name          text not null,
+			-- migration file name.
+			filename      text not null,
+			-- migration sql content as found in Up migration file.
+			up            text not null,
+			-- migration sql content as found in Down migration file.
+			down          text not null,
+			-- status of this migration
+			status        text constraint %s check (status in ('up', 'down')),
+			-- checksum of the up and down sql.
+			checksum      text,
+			-- whether the migration was applied after one with a greater id.
+			out_of_order  boolean not null default false,
+			-- order in which the migration was last applied.
+			sequence      bigint,
+			-- milliseconds it took to last apply or revert the migration.
+			duration_ms   bigint,
+			-- database user, host and application version that last applied
+			-- or reverted the migration.
+			db_user       text,
+			hostname      text,
+			app_version   text,
+			-- timestamp of when the migration was created.
+			created_at    timestamptz not null default current_timestamp,
+			-- timestamp of when the migration was updated.
+			updated_at    timestamptz not null,
 
-		if err := p.migrate(m, nil); err != nil {
-			return err
-		}
-	}
-	return nil
+			primary key (id)
+		);
+	`, table, statusCheck)
 }
 
-// migrate implements the main migration process.
-func (p *postgres) migrate(m *Migration, currTx *sql.Tx) error {
-	tx := currTx
-	if tx == nil {
-		var err error
-		tx, err = p.db.Begin()
-		if err != nil {
-			log.Printf("[ERROR] %#v", err)
-			return ErrMigrationFailed
-		}
-	}
-
-	ms, err := p.Migrations(m.ID)
-	if err != nil {
-		log.Printf("[ERROR] %#v", err)
-		return ErrMigrationFailed
-	}
+// CreateHistoryTable returns the statement creating the history table.
+func (postgresDialect) CreateHistoryTable(table string) string {
+	return fmt.Sprintf(`
+		create table if not exists %s (
+			-- order in which transitions happened.
+			id            bigserial primary key,
+			-- id, name and file name of the migration.
+			migration_id  text not null,
+			name          text not null,
+			filename      text not null,
+			-- whether the migration was applied or reverted.
+			direction     text not null,
+			-- checksum of the up and down sql.
+			checksum      text,
+			-- whether the migration was applied after one with a greater id.
+			out_of_order  boolean not null default false,
+			-- milliseconds it took to apply or revert the migration.
+			duration_ms   bigint,
+			-- database user, host and application version that applied or
+			-- reverted the migration.
+			db_user       text,
+			hostname      text,
+			app_version   text,
+			-- timestamp of the transition.
+			created_at    timestamptz not null default current_timestamp
+		);
+	`, table)
+}
 
-	exists := len(ms) > 0
+// CurrentUser returns current_user.
+func (postgresDialect) CurrentUser() string {
+	return "current_user"
+}
 
-	if exists && ms[0].Status == "up" {
-		tx.Rollback()
-		return nil
+// AddColumn adds the column with the type it has in CreateTable.
+func (postgresDialect) AddColumn(table, column string) string {
+	types := map[string]string{
+		"out_of_order": "boolean not null default false",
+		"sequence":     "bigint",
+		"duration_ms":  "bigint",
+		"db_user":      "text",
+		"hostname":     "text",
+		"app_version":  "text",
 	}
+	return fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, column, types[column])
+}
 
-	if _, err := tx.Exec(m.Up); err != nil {
-		log.Printf("[ERROR] %#v", err)
-		log.Printf("[ERROR] %s", m.Up)
-		tx.Rollback()
-		return ErrMigrationFailed
-	}
+// Upsert records a migration with ON CONFLICT.
+func (postgresDialect) Upsert(table string) string {
+	return fmt.Sprintf(`
+		INSERT INTO %s (
+			id, name, filename, up, down, status, checksum, out_of_order, sequence,
+			duration_ms, hostname, app_version, db_user, created_at, updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, current_user, now(), now())
+		ON CONFLICT (id) DO UPDATE
+		SET    status = excluded.status, up = excluded.up, down = excluded.down,
+		       checksum = excluded.checksum, out_of_order = excluded.out_of_order,
+		       sequence = excluded.sequence, duration_ms = excluded.duration_ms,
+		       hostname = excluded.hostname, app_version = excluded.app_version,
+		       db_user = excluded.db_user, updated_at = excluded.updated_at
+	`, table)
+}
 
-	if !exists {
-		if _, err := tx.Exec(`
-			INSERT INTO schema_migrations (
-				id, name, filename, up, down, status, created_at, updated_at
-			) VALUES ($1, $2, $3, $4, $5, $6, now(), $7) on conflict do nothing;
-		`, m.ID, m.Name, m.Filename, m.Up, m.Down, "up", m.UpdatedAt); err != nil {
-			log.Printf("[ERROR] %#v", err)
-			tx.Rollback()
-			return ErrRegisteringMigration
-		}
-	} else {
-		if _, err := tx.Exec(`
-			UPDATE schema_migrations
-			SET    status = $1, up = $2, down = $3, updated_at = now()
-			WHERE  id = $4`, "up", m.Up, m.Down, m.ID); err != nil {
-			log.Printf("[ERROR] %#v", err)
-			tx.Rollback()
-			return ErrUpdatingMigration
-		}
-	}
+// TryLock takes a session level advisory lock.
+func (postgresDialect) TryLock() string {
+	return `SELECT pg_try_advisory_lock($1)`
+}
 
-	if err := tx.Commit(); err != nil {
-		log.Printf("[ERROR] %#v", err)
-		tx.Rollback()
-		return ErrMigrationFailed
-	}
+// Unlock releases the advisory lock taken by TryLock.
+func (postgresDialect) Unlock() string {
+	return `SELECT pg_advisory_unlock($1)`
+}
 
-	return nil
+// Transactional returns true, Postgres rolls back schema changes.
+func (postgresDialect) Transactional() bool {
+	return true
 }
 
-// Migrations returns information about a list of migration IDs.
-func (p *postgres) Migrations(IDs ...string) ([]*Migration, error) {
-	query := `
-		SELECT id, name, filename, up, down, status, created_at, updated_at
-		FROM schema_migrations
-	`
-
-	hasIDs := len(IDs) > 0
-	if hasIDs {
-		query += ` WHERE id IN (`
-		for i := range IDs {
-			query += fmt.Sprintf("$%d,", i+1)
+// Session sets the statement and lock timeouts of the connection.
+func (postgresDialect) Session(ctx context.Context, conn Queryer, c Config) ([]string, error) {
+	var reset []string
+	if c.StatementTimeout > 0 {
+		if _, err := conn.ExecContext(ctx, fmt.Sprintf("SET statement_timeout = %d", c.StatementTimeout.Milliseconds())); err != nil {
+			return nil, err
 		}
-		query = strings.TrimSuffix(query, ",")
-		query += `)`
+		reset = append(reset, "RESET statement_timeout")
 	}
 
-	query += ` ORDER by id DESC `
-
-	var err error
-	var rows *sql.Rows
-	if hasIDs {
-		// This is so unfortunate
-		new := make([]interface{}, len(IDs))
-		for i, v := range IDs {
-			new[i] = interface{}(v)
+	if c.LockTimeout > 0 {
+		if _, err := conn.ExecContext(ctx, fmt.Sprintf("SET lock_timeout = %d", c.LockTimeout.Milliseconds())); err != nil {
+			return nil, err
 		}
-		//log.Printf("%s", query)
-		rows, err = p.db.Query(query, new...)
-	} else {
-		rows, err = p.db.Query(query)
-	}
-
-	if err != nil {
-		debug.PrintStack()
-		log.Printf("[ERROR] %#v", err)
-		return nil, ErrGettingMigrations
+		reset = append(reset, "RESET lock_timeout")
 	}
-	defer rows.Close()
+	return reset, nil
+}
 
-	var migrations []*Migration
-	for rows.Next() {
-		var id, name, filename, up, down, status string
-		var createdAt, updatedAt time.Time
-
-		rows.Scan(&id, &name, &filename, &up, &down, &status, &createdAt, &updatedAt)
-		m := new(Migration)
-		m.ID = id
-		m.Name = name
-		m.Filename = filename
-		m.Up = up
-		m.Down = down
-		m.Status = status
-		m.CreatedAt = createdAt
-		m.UpdatedAt = updatedAt
-
-		migrations = append(migrations, m)
-	}
+// BoundsStatements returns true, statement_timeout applies to every statement.
+func (postgresDialect) BoundsStatements() bool {
+	return true
+}
 
-	if err = rows.Err(); err != nil {
-		debug.PrintStack()
-		log.Printf("[ERROR] %#v", err)
-		return nil, ErrGettingMigrations
-	}
-	return migrations, nil
+// Check does nothing, Postgres validates changes as they are made.
+func (postgresDialect) Check(ctx context.Context, q Queryer) error {
+	return nil
 }
@@ -2,21 +2,32 @@
 // License, version 2.0. If a copy of the MPL was not distributed with this
 // file, You can obtain one at http://mozilla.org/MPL/2.0/.
 
+//go:build postgres
+// +build postgres
+
 package migrator
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"io/ioutil"
 	"log"
+	"log/slog"
 	"os"
 	"os/exec"
 	"strings"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	"github.com/c4milo/migrator/migrations"
 	"github.com/hooklift/assert"
+	"github.com/lib/pq"
 )
 
 func init() {
@@ -113,7 +124,7 @@ func TestMain(m *testing.M) {
 }
 
 func TestMigrate(t *testing.T) {
-	m, err := NewMigrator(db, Postgres, migrations.Asset, migrations.AssetDir)
+	m, err := NewMigratorFS(db, Postgres, migrations.FS, "postgres")
 	assert.Ok(t, err)
 
 	err = m.Init()
@@ -129,7 +140,7 @@ func TestMigrate(t *testing.T) {
 }
 
 func TestRedo(t *testing.T) {
-	m, err := NewMigrator(db, Postgres, migrations.Asset, migrations.AssetDir)
+	m, err := NewMigratorFS(db, Postgres, migrations.FS, "postgres")
 	assert.Ok(t, err)
 
 	err = m.Init()
@@ -146,7 +157,7 @@ func TestRedo(t *testing.T) {
 }
 
 func TestRollback(t *testing.T) {
-	m, err := NewMigrator(db, Postgres, migrations.Asset, migrations.AssetDir)
+	m, err := NewMigratorFS(db, Postgres, migrations.FS, "postgres")
 	assert.Ok(t, err)
 
 	wor := db.QueryRow("select to_regclass('tokens')")
@@ -189,7 +200,7 @@ func TestRollback(t *testing.T) {
 }
 
 func TestMigrations(t *testing.T) {
-	m, err := NewMigrator(db, Postgres, migrations.Asset, migrations.AssetDir)
+	m, err := NewMigratorFS(db, Postgres, migrations.FS, "postgres")
 	assert.Ok(t, err)
 
 	ms, err := m.Migrations()
@@ -198,7 +209,7 @@ func TestMigrations(t *testing.T) {
 }
 
 func TestUpDown(t *testing.T) {
-	m, err := NewMigrator(db, Postgres, migrations.Asset, migrations.AssetDir)
+	m, err := NewMigratorFS(db, Postgres, migrations.FS, "postgres")
 	assert.Ok(t, err)
 
 	err = m.Init()
@@ -223,3 +234,372 @@ func TestUpDown(t *testing.T) {
 	wor.Scan(&tt)
 	assert.Equals(t, "tokens", tt)
 }
+
+func TestLockTimeout(t *testing.T) {
+	m, err := NewMigratorFS(db, Postgres, migrations.FS, "postgres", WithLockWait(500*time.Millisecond))
+	assert.Ok(t, err)
+
+	conn, err := db.Conn(context.Background())
+	assert.Ok(t, err)
+	defer conn.Close()
+
+	_, err = conn.ExecContext(context.Background(), "select pg_advisory_lock($1)", DefaultLockKey)
+	assert.Ok(t, err)
+
+	err = m.Migrate()
+	assert.Cond(t, errors.Is(err, ErrLockTimeout), "expected ErrLockTimeout, got %v", err)
+
+	_, err = conn.ExecContext(context.Background(), "select pg_advisory_unlock($1)", DefaultLockKey)
+	assert.Ok(t, err)
+
+	err = m.Migrate()
+	assert.Ok(t, err)
+}
+
+func TestMigrateContextCanceled(t *testing.T) {
+	m, err := NewMigratorFS(db, Postgres, migrations.FS, "postgres")
+	assert.Ok(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = m.MigrateContext(ctx)
+	assert.Equals(t, context.Canceled, err)
+}
+
+func TestVerify(t *testing.T) {
+	m, err := NewMigratorFS(db, Postgres, migrations.FS, "postgres", WithStrictChecksums())
+	assert.Ok(t, err)
+
+	err = m.Migrate()
+	assert.Ok(t, err)
+
+	drift, err := m.Verify()
+	assert.Ok(t, err)
+	assert.Equals(t, 0, len(drift))
+
+	_, err = db.Exec("update schema_migrations set checksum = 'edited' where id = '0003'")
+	assert.Ok(t, err)
+
+	drift, err = m.Verify()
+	assert.Ok(t, err)
+	assert.Equals(t, 1, len(drift))
+	assert.Equals(t, "0003", drift[0].ID)
+
+	err = m.Migrate()
+	assert.Cond(t, errors.Is(err, ErrChecksumMismatch), "expected a checksum mismatch, got %v", err)
+	assert.Equals(t, "checksum-mismatch: 0003", err.Error())
+
+	_, err = db.Exec("update schema_migrations set checksum = null where id = '0003'")
+	assert.Ok(t, err)
+
+	err = m.Migrate()
+	assert.Ok(t, err)
+}
+
+func TestMigrationErrorDetails(t *testing.T) {
+	fsys := fstest.MapFS{
+		"9001_broken_up.sql":   {Data: []byte("create tabel broken (id int);")},
+		"9001_broken_down.sql": {Data: []byte("drop table broken;")},
+	}
+
+	m, err := NewMigratorFS(db, Postgres, fsys, ".")
+	assert.Ok(t, err)
+
+	err = m.Migrate()
+	assert.Cond(t, errors.Is(err, ErrMigrationFailed), "expected ErrMigrationFailed, got %v", err)
+
+	var merr *MigrationError
+	assert.Cond(t, errors.As(err, &merr), "expected *MigrationError, got %T", err)
+	assert.Equals(t, "9001", merr.ID)
+	assert.Equals(t, PhaseExec, merr.Phase)
+
+	var pqErr *pq.Error
+	assert.Cond(t, errors.As(err, &pqErr), "expected *pq.Error, got %T", merr.Err)
+	assert.Equals(t, "42601", string(pqErr.Code))
+}
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	m, err := NewMigratorFS(db, Postgres, migrations.FS, "postgres", WithLogger(logger))
+	assert.Ok(t, err)
+
+	err = m.Migrate()
+	assert.Ok(t, err)
+
+	err = m.Redo()
+	assert.Ok(t, err)
+
+	var events []map[string]interface{}
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var e map[string]interface{}
+		assert.Ok(t, dec.Decode(&e))
+		events = append(events, e)
+	}
+
+	assert.Equals(t, 4, len(events))
+	assert.Equals(t, "migration started", events[0]["msg"])
+	assert.Equals(t, "down", events[0]["direction"])
+	assert.Equals(t, "0007", events[0]["id"])
+	assert.Equals(t, "migration finished", events[3]["msg"])
+	assert.Equals(t, "up", events[3]["direction"])
+}
+
+func TestOptions(t *testing.T) {
+	fsys := fstest.MapFS{
+		"sql/9101_create-widgets-table_up.sql":   {Data: []byte("create table widgets (id int);")},
+		"sql/9101_create-widgets-table_down.sql": {Data: []byte("drop table widgets;")},
+	}
+
+	m, err := NewMigratorFS(db, Postgres, fsys, ".",
+		WithBaseDir("sql"),
+		WithSchema("public"),
+		WithTableName("widget_migrations"),
+	)
+	assert.Ok(t, err)
+
+	err = m.Migrate()
+	assert.Ok(t, err)
+
+	row := db.QueryRow("select count(*) from public.widget_migrations where id = '9101'")
+	var tm int
+	row.Scan(&tm)
+	assert.Equals(t, 1, tm)
+
+	row2 := db.QueryRow("select count(*) from schema_migrations where id = '9101'")
+	var tm2 int
+	row2.Scan(&tm2)
+	assert.Equals(t, 0, tm2)
+
+	err = m.Rollback()
+	assert.Ok(t, err)
+
+	wor := db.QueryRow("select to_regclass('widgets')")
+	var tt string
+	wor.Scan(&tt)
+	assert.Equals(t, "", tt)
+}
+
+func TestSchema(t *testing.T) {
+	_, err := db.Exec(`create schema "Billing"`)
+	assert.Ok(t, err)
+
+	fsys := fstest.MapFS{
+		"9201_create-invoices-table_up.sql":   {Data: []byte(`create table "Billing".invoices (id int);`)},
+		"9201_create-invoices-table_down.sql": {Data: []byte(`drop table "Billing".invoices;`)},
+	}
+
+	m, err := NewMigratorFS(db, Postgres, fsys, ".",
+		WithSchema("Billing"),
+		WithTableName("Migrations"),
+	)
+	assert.Ok(t, err)
+
+	err = m.Migrate()
+	assert.Ok(t, err)
+
+	row := db.QueryRow(`select count(*) from "Billing"."Migrations" where status = 'up'`)
+	var tm int
+	row.Scan(&tm)
+	assert.Equals(t, 1, tm)
+
+	err = m.Init()
+	assert.Ok(t, err)
+}
+
+func TestUpgrade(t *testing.T) {
+	_, err := db.Exec(`
+		create extension if not exists citext;
+		create type legacy_status_type as enum ('up', 'down');
+		create table legacy_migrations (
+			id            citext not null,
+			name          text   not null,
+			filename      text   not null,
+			up            text   not null,
+			down          text   not null,
+			status        legacy_status_type,
+			created_at    timestamptz not null default current_timestamp,
+			updated_at    timestamptz not null,
+
+			primary key (id)
+		);
+		insert into legacy_migrations values ('9301', 'legacy', '9301_legacy_up.sql', 'select 1', 'select 1', 'up', now(), now());
+	`)
+	assert.Ok(t, err)
+
+	m, err := NewMigratorFS(db, Postgres, fstest.MapFS{}, ".", WithTableName("legacy_migrations"))
+	assert.Ok(t, err)
+
+	rows, err := db.Query(`
+		select column_name, udt_name from information_schema.columns
+		where table_name = 'legacy_migrations' and column_name in ('id', 'status', 'checksum', 'out_of_order')
+		order by column_name`)
+	assert.Ok(t, err)
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var name, udt string
+		assert.Ok(t, rows.Scan(&name, &udt))
+		columns = append(columns, name+" "+udt)
+	}
+	assert.Equals(t, []string{"checksum text", "id text", "out_of_order bool", "status text"}, columns)
+
+	ms, err := m.Migrations("9301")
+	assert.Ok(t, err)
+	assert.Equals(t, 1, len(ms))
+	assert.Equals(t, "up", ms[0].Status)
+
+	_, err = db.Exec(`update legacy_migrations set status = 'sideways'`)
+	assert.Cond(t, err != nil, "expected the status check constraint to be enforced")
+}
+
+func TestNoTransaction(t *testing.T) {
+	fsys := fstest.MapFS{
+		"9401_index-widgets_up.sql": {Data: []byte(`-- migrator:no-transaction
+			create table if not exists widgets (id int, name text);
+			create index concurrently if not exists widgets_name_idx on widgets (name);
+		`)},
+		"9401_index-widgets_down.sql": {Data: []byte(`-- migrator:no-transaction
+			drop index concurrently if exists widgets_name_idx;
+			drop table widgets;
+		`)},
+	}
+
+	m, err := NewMigratorFS(db, Postgres, fsys, ".", WithTableName("concurrent_migrations"))
+	assert.Ok(t, err)
+
+	err = m.Migrate()
+	assert.Ok(t, err)
+
+	var idx string
+	db.QueryRow("select to_regclass('widgets_name_idx')").Scan(&idx)
+	assert.Equals(t, "widgets_name_idx", idx)
+
+	err = m.Rollback()
+	assert.Ok(t, err)
+
+	ms, err := m.Migrations("9401")
+	assert.Ok(t, err)
+	assert.Equals(t, "down", ms[0].Status)
+}
+
+func TestRecordedDetails(t *testing.T) {
+	fsys := fstest.MapFS{
+		"9501_create-gadgets_up.sql":   {Data: []byte("create table gadgets (id int); select pg_sleep(0.01);")},
+		"9501_create-gadgets_down.sql": {Data: []byte("drop table gadgets;")},
+		"9502_create-gizmos_up.sql":    {Data: []byte("create table gizmos (id int);")},
+		"9502_create-gizmos_down.sql":  {Data: []byte("drop table gizmos;")},
+	}
+
+	m, err := NewMigratorFS(db, Postgres, fsys, ".",
+		WithTableName("details_migrations"),
+		WithAppVersion("v1.2.3"),
+	)
+	assert.Ok(t, err)
+
+	err = m.Migrate()
+	assert.Ok(t, err)
+
+	err = m.Redo()
+	assert.Ok(t, err)
+
+	hostname, err := os.Hostname()
+	assert.Ok(t, err)
+
+	ms, err := m.Migrations()
+	assert.Ok(t, err)
+	assert.Equals(t, 2, len(ms))
+	assert.Equals(t, "9502", ms[0].ID)
+	assert.Equals(t, int64(3), ms[0].Sequence)
+	assert.Equals(t, int64(1), ms[1].Sequence)
+	assert.Cond(t, ms[1].Duration >= 10*time.Millisecond, "expected 9501 to take at least 10ms, got %s", ms[1].Duration)
+	for _, m := range ms {
+		assert.Equals(t, "migrator", m.DBUser)
+		assert.Equals(t, hostname, m.Hostname)
+		assert.Equals(t, "v1.2.3", m.AppVersion)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	fsys := fstest.MapFS{
+		"9601_create-sprockets_up.sql":   {Data: []byte("create table sprockets (id int);")},
+		"9601_create-sprockets_down.sql": {Data: []byte("drop table sprockets;")},
+		"9602_create-cogs_up.sql":        {Data: []byte("create table cogs (id int);")},
+		"9602_create-cogs_down.sql":      {Data: []byte("drop table cogs;")},
+	}
+
+	m, err := NewMigratorFS(db, Postgres, fsys, ".",
+		WithTableName("history_migrations"),
+		WithAppVersion("v1.2.3"),
+	)
+	assert.Ok(t, err)
+
+	err = m.Migrate()
+	assert.Ok(t, err)
+
+	err = m.Rollback()
+	assert.Ok(t, err)
+
+	err = m.Migrate()
+	assert.Ok(t, err)
+
+	hostname, err := os.Hostname()
+	assert.Ok(t, err)
+
+	history, err := m.History()
+	assert.Ok(t, err)
+
+	var transitions []string
+	for _, h := range history {
+		transitions = append(transitions, string(h.Direction)+" "+h.ID)
+		assert.Equals(t, "migrator", h.DBUser)
+		assert.Equals(t, hostname, h.Hostname)
+		assert.Equals(t, "v1.2.3", h.AppVersion)
+		assert.Cond(t, h.Checksum != "", "expected the checksum of %s to be recorded", h.ID)
+		assert.Cond(t, !h.CreatedAt.IsZero(), "expected created_at to be set")
+	}
+	assert.Equals(t, []string{"up 9601", "up 9602", "down 9602", "up 9602"}, transitions)
+
+	history, err = m.History("9602")
+	assert.Ok(t, err)
+	assert.Equals(t, 3, len(history))
+	assert.Equals(t, "9602_create-cogs_up.sql", history[1].Filename)
+	assert.Equals(t, DirectionDown, history[1].Direction)
+
+	// Transitions are kept even if the migrations table is cleared.
+	_, err = db.Exec("delete from history_migrations")
+	assert.Ok(t, err)
+
+	history, err = m.History()
+	assert.Ok(t, err)
+	assert.Equals(t, 4, len(history))
+}
+
+func TestNewPostgres(t *testing.T) {
+	sub, err := fs.Sub(migrations.FS, "postgres")
+	assert.Ok(t, err)
+
+	entries, err := fs.ReadDir(sub, ".")
+	assert.Ok(t, err)
+
+	var paths []string
+	for _, e := range entries {
+		paths = append(paths, e.Name())
+	}
+
+	m, err := NewPostgres(db, paths, func(name string) ([]byte, error) {
+		return fs.ReadFile(sub, name)
+	})
+	assert.Ok(t, err)
+
+	err = m.Migrate()
+	assert.Ok(t, err)
+
+	ms, err := m.Migrations()
+	assert.Ok(t, err)
+	assert.Equals(t, 7, len(ms))
+}
@@ -0,0 +1,230 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+//go:build sqlite
+// +build sqlite
+
+package migrator
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	// Loads SQLite driver
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func init() {
+	Register(SQLite, NewSQLite)
+}
+
+// sqliteDialect implements Dialect for SQLite, mostly meant for local
+// development and tests.
+//
+// SQLite supports transactional DDL, so every migration runs in its own
+// transaction. Its ALTER TABLE, however, can't change or drop columns and
+// constraints, which requires creating a new table, copying the data over and
+// renaming it. So that such migrations don't trip over foreign keys, they are
+// turned off while a migration runs, since SQLite ignores the foreign_keys
+// pragma inside a transaction, and checked with foreign_key_check before
+// committing.
+//
+// SQLite has no advisory locks. Migrations are serialized within the process,
+// while other processes rely on SQLite's own locking, bounded by the lock
+// timeout, while statements are bounded through their context. In-memory
+// databases must use a shared cache, for instance, "file::memory:?cache=shared",
+// so every connection of the pool sees the same database.
+type sqliteDialect struct{}
+
+// NewSQLite creates SQLite driver
+func NewSQLite(db *sql.DB, c Config) (Driver, error) {
+	return NewSQLDriver(db, sqliteDialect{}, c), nil
+}
+
+// Quote returns name quoted as an identifier and qualified with schema, the
+// name of an attached database, if given.
+func (sqliteDialect) Quote(schema, name string) string {
+	quote := func(s string) string {
+		return `"` + strings.Replace(s, `"`, `""`, -1) + `"`
+	}
+
+	if schema == "" {
+		return quote(name)
+	}
+	return quote(schema) + "." + quote(name)
+}
+
+// Placeholder returns ?.
+func (sqliteDialect) Placeholder(n int) string {
+	return "?"
+}
+
+// CreateTable returns the statement creating the migrations table.
+func (sqliteDialect) CreateTable(table, statusCheck string) string {
+	return fmt.Sprintf(`
+		create table if not exists %s (
+			-- migration identifier as found in migration file name.
+			id            text not null,
+			-- migration name as found in migration file name.
+			name          text not null,
+			-- migration file name.
+			filename      text not null,
+			-- migration sql content as found in Up migration file.
+			up            text not null,
+			-- migration sql content as found in Down migration file.
+			down          text not null,
+			-- status of this migration
+			status        text constraint %s check (status in ('up', 'down')),
+			-- checksum of the up and down sql.
+			checksum      text,
+			-- whether the migration was applied after one with a greater id.
+			out_of_order  boolean not null default false,
+			-- order in which the migration was last applied.
+			sequence      integer,
+			-- milliseconds it took to last apply or revert the migration.
+			duration_ms   integer,
+			-- host and application version that last applied or reverted the
+			-- migration, SQLite has no users.
+			db_user       text,
+			hostname      text,
+			app_version   text,
+			-- timestamp of when the migration was created.
+			created_at    timestamp not null default current_timestamp,
+			-- timestamp of when the migration was updated.
+			updated_at    timestamp not null default current_timestamp,
+
+			primary key (id)
+		);
+	`, table, statusCheck)
+}
+
+// CreateHistoryTable returns the statement creating the history table.
+func (sqliteDialect) CreateHistoryTable(table string) string {
+	return fmt.Sprintf(`
+		create table if not exists %s (
+			-- order in which transitions happened.
+			id            integer primary key autoincrement,
+			-- id, name and file name of the migration.
+			migration_id  text not null,
+			name          text not null,
+			filename      text not null,
+			-- whether the migration was applied or reverted.
+			direction     text not null,
+			-- checksum of the up and down sql.
+			checksum      text,
+			-- whether the migration was applied after one with a greater id.
+			out_of_order  boolean not null default false,
+			-- milliseconds it took to apply or revert the migration.
+			duration_ms   integer,
+			-- host and application version that applied or reverted the
+			-- migration, SQLite has no users.
+			db_user       text,
+			hostname      text,
+			app_version   text,
+			-- timestamp of the transition.
+			created_at    timestamp not null default current_timestamp
+		);
+	`, table)
+}
+
+// CurrentUser returns NULL, SQLite has no users.
+func (sqliteDialect) CurrentUser() string {
+	return "NULL"
+}
+
+// AddColumn adds the column with the type it has in CreateTable.
+func (sqliteDialect) AddColumn(table, column string) string {
+	types := map[string]string{
+		"out_of_order": "boolean not null default false",
+		"sequence":     "integer",
+		"duration_ms":  "integer",
+		"db_user":      "text",
+		"hostname":     "text",
+		"app_version":  "text",
+	}
+	return fmt.Sprintf(`alter table %s add column %s %s`, table, column, types[column])
+}
+
+// Upsert records a migration with ON CONFLICT.
+func (sqliteDialect) Upsert(table string) string {
+	return fmt.Sprintf(`
+		INSERT INTO %s (
+			id, name, filename, up, down, status, checksum, out_of_order, sequence,
+			duration_ms, hostname, app_version
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE
+		SET    status = excluded.status, up = excluded.up, down = excluded.down,
+		       checksum = excluded.checksum, out_of_order = excluded.out_of_order,
+		       sequence = excluded.sequence, duration_ms = excluded.duration_ms,
+		       hostname = excluded.hostname, app_version = excluded.app_version,
+		       updated_at = current_timestamp
+	`, table)
+}
+
+// TryLock returns an empty statement, SQLite has no advisory locks.
+func (sqliteDialect) TryLock() string {
+	return ""
+}
+
+// Unlock returns an empty statement, SQLite has no advisory locks.
+func (sqliteDialect) Unlock() string {
+	return ""
+}
+
+// Transactional returns true, SQLite rolls back schema changes.
+func (sqliteDialect) Transactional() bool {
+	return true
+}
+
+// Session turns foreign keys off, since SQLite ignores the foreign_keys pragma
+// inside a transaction, and applies the lock timeout.
+func (sqliteDialect) Session(ctx context.Context, conn Queryer, c Config) ([]string, error) {
+	var foreignKeys bool
+	if err := conn.QueryRowContext(ctx, `PRAGMA foreign_keys`).Scan(&foreignKeys); err != nil {
+		return nil, err
+	}
+
+	var busyTimeout int64
+	if err := conn.QueryRowContext(ctx, `PRAGMA busy_timeout`).Scan(&busyTimeout); err != nil {
+		return nil, err
+	}
+
+	pragmas := `PRAGMA foreign_keys = OFF;`
+	if c.LockTimeout > 0 {
+		pragmas += fmt.Sprintf(`PRAGMA busy_timeout = %d;`, c.LockTimeout.Milliseconds())
+	}
+
+	if _, err := conn.ExecContext(ctx, pragmas); err != nil {
+		return nil, err
+	}
+	return []string{fmt.Sprintf(`PRAGMA foreign_keys = %t; PRAGMA busy_timeout = %d;`, foreignKeys, busyTimeout)}, nil
+}
+
+// BoundsStatements returns false, SQLite has no statement timeout.
+func (sqliteDialect) BoundsStatements() bool {
+	return false
+}
+
+// Check returns an error if the changes made by a migration left any foreign
+// key pointing to a missing row.
+func (sqliteDialect) Check(ctx context.Context, q Queryer) error {
+	rows, err := q.QueryContext(ctx, `PRAGMA foreign_key_check`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		var table, parent string
+		var rowid sql.NullInt64
+		var fkid int64
+		if err := rows.Scan(&table, &rowid, &parent, &fkid); err != nil {
+			return err
+		}
+		return fmt.Errorf("foreign key violation: row %d of table %s references a missing row of %s", rowid.Int64, table, parent)
+	}
+	return rows.Err()
+}
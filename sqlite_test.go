@@ -0,0 +1,300 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+//go:build sqlite
+// +build sqlite
+
+package migrator
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/c4milo/migrator/migrations"
+	"github.com/hooklift/assert"
+)
+
+// openSQLite opens a new SQLite database, with foreign keys enforced, that is
+// removed once the test finishes.
+func openSQLite(t *testing.T) *sql.DB {
+	sdb, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "migrator.db")+"?_foreign_keys=on")
+	assert.Ok(t, err)
+	t.Cleanup(func() { sdb.Close() })
+	return sdb
+}
+
+func TestSQLiteMigrate(t *testing.T) {
+	sdb := openSQLite(t)
+
+	m, err := NewMigratorFS(sdb, SQLite, migrations.FS, "sqlite")
+	assert.Ok(t, err)
+
+	err = m.Migrate()
+	assert.Ok(t, err)
+
+	_, err = sdb.Exec("insert into accounts (id, email) values (1, 'a@example.com')")
+	assert.Ok(t, err)
+	_, err = sdb.Exec("insert into tokens (id, account_id) values ('t1', 1)")
+	assert.Ok(t, err)
+
+	ms, err := m.Migrations()
+	assert.Ok(t, err)
+	assert.Equals(t, 3, len(ms))
+	assert.Equals(t, "0003", ms[0].ID)
+	assert.Equals(t, "up", ms[0].Status)
+	assert.Cond(t, !ms[0].CreatedAt.IsZero(), "expected created_at to be set")
+
+	// Rebuilds accounts again while tokens references it.
+	err = m.Redo()
+	assert.Ok(t, err)
+
+	var fk bool
+	err = sdb.QueryRow("pragma foreign_keys").Scan(&fk)
+	assert.Ok(t, err)
+	assert.Cond(t, fk, "expected foreign keys to be turned back on")
+
+	err = m.Rollback(3)
+	assert.Ok(t, err)
+
+	var tables int
+	err = sdb.QueryRow("select count(*) from sqlite_master where type = 'table' and name in ('accounts', 'tokens')").Scan(&tables)
+	assert.Ok(t, err)
+	assert.Equals(t, 0, tables)
+
+	steps, err := m.Plan(CommandMigrate)
+	assert.Ok(t, err)
+	assert.Equals(t, 3, len(steps))
+
+	err = m.Up("0001")
+	assert.Ok(t, err)
+
+	err = m.Down("0001")
+	assert.Ok(t, err)
+
+	err = m.Migrate()
+	assert.Ok(t, err)
+
+	drift, err := m.Verify()
+	assert.Ok(t, err)
+	assert.Equals(t, 0, len(drift))
+	err = m.RollbackTo("0001")
+	assert.Ok(t, err)
+
+	ms, err = m.Migrations()
+	assert.Ok(t, err)
+	assert.Equals(t, "down", ms[0].Status)
+	assert.Equals(t, "down", ms[1].Status)
+	assert.Equals(t, "up", ms[2].Status)
+
+	err = m.Reset()
+	assert.Ok(t, err)
+
+	err = sdb.QueryRow("select count(*) from sqlite_master where type = 'table' and name in ('accounts', 'tokens')").Scan(&tables)
+	assert.Ok(t, err)
+	assert.Equals(t, 0, tables)
+}
+
+func TestSQLiteForeignKeyCheck(t *testing.T) {
+	sdb := openSQLite(t)
+
+	fsys := fstest.MapFS{
+		"0001_create-tables_up.sql": {Data: []byte(`
+			create table parents (id integer primary key);
+			create table children (id integer primary key, parent_id integer references parents (id));
+			insert into parents values (1);
+			insert into children values (1, 1);
+		`)},
+		"0001_create-tables_down.sql": {Data: []byte(`drop table children; drop table parents;`)},
+		"0002_drop-parents_up.sql":    {Data: []byte(`drop table parents;`)},
+		"0002_drop-parents_down.sql":  {Data: []byte(`create table parents (id integer primary key);`)},
+	}
+
+	m, err := NewMigratorFS(sdb, SQLite, fsys, ".")
+	assert.Ok(t, err)
+
+	err = m.Migrate()
+	assert.Cond(t, errors.Is(err, ErrMigrationFailed), "expected ErrMigrationFailed, got %v", err)
+
+	var merr *MigrationError
+	assert.Cond(t, errors.As(err, &merr), "expected *MigrationError, got %T", err)
+	assert.Equals(t, "0002", merr.ID)
+
+	var parents int
+	err = sdb.QueryRow("select count(*) from parents").Scan(&parents)
+	assert.Ok(t, err)
+	assert.Equals(t, 1, parents)
+}
+
+func TestSQLiteGoMigration(t *testing.T) {
+	sdb := openSQLite(t)
+
+	up := func(ctx context.Context, tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, "insert into accounts (id, email) values (1, 'a@example.com')")
+		return err
+	}
+
+	down := func(ctx context.Context, tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, "delete from accounts")
+		return err
+	}
+
+	m, err := NewMigratorFS(sdb, SQLite, migrations.FS, "sqlite", WithGoMigration("0001a", "seed-accounts", up, down))
+	assert.Ok(t, err)
+
+	err = m.Migrate()
+	assert.Ok(t, err)
+
+	var accounts int
+	err = sdb.QueryRow("select count(*) from accounts").Scan(&accounts)
+	assert.Ok(t, err)
+	assert.Equals(t, 1, accounts)
+
+	ms, err := m.Migrations("0001a")
+	assert.Ok(t, err)
+	assert.Equals(t, 1, len(ms))
+	assert.Equals(t, "up", ms[0].Status)
+
+	err = m.Rollback(3)
+	assert.Ok(t, err)
+
+	err = sdb.QueryRow("select count(*) from accounts").Scan(&accounts)
+	assert.Ok(t, err)
+	assert.Equals(t, 0, accounts)
+}
+
+func TestSQLiteNoTransaction(t *testing.T) {
+	sdb := openSQLite(t)
+
+	fsys := fstest.MapFS{
+		"0001_create-tables_up.sql": {Data: []byte(`-- migrator:no-transaction
+			create table a (id integer primary key);
+			create table b (id integer primary key);
+			create table a (id integer primary key);
+		`)},
+		"0001_create-tables_down.sql": {Data: []byte(`drop table b; drop table a;`)},
+	}
+
+	m, err := NewMigratorFS(sdb, SQLite, fsys, ".")
+	assert.Ok(t, err)
+
+	err = m.Migrate()
+	assert.Cond(t, errors.Is(err, ErrMigrationFailed), "expected ErrMigrationFailed, got %v", err)
+
+	var merr *MigrationError
+	assert.Cond(t, errors.As(err, &merr), "expected *MigrationError, got %T", err)
+	assert.Equals(t, "create table a (id integer primary key)", merr.SQL)
+
+	// Statements before the failing one stay applied.
+	var tables int
+	err = sdb.QueryRow("select count(*) from sqlite_master where type = 'table' and name in ('a', 'b')").Scan(&tables)
+	assert.Ok(t, err)
+	assert.Equals(t, 2, tables)
+
+	ms, err := m.Migrations()
+	assert.Ok(t, err)
+	assert.Equals(t, 0, len(ms))
+
+	steps, err := m.Plan(CommandMigrate)
+	assert.Ok(t, err)
+	assert.Cond(t, steps[0].NoTransaction, "expected step to run without a transaction")
+}
+
+func TestSQLiteOutOfOrder(t *testing.T) {
+	sdb := openSQLite(t)
+
+	// Created by a version of this package predating out_of_order.
+	_, err := sdb.Exec(`
+		create table schema_migrations (
+			id text not null, name text not null, filename text not null,
+			up text not null, down text not null, status text, checksum text,
+			created_at timestamp not null default current_timestamp,
+			updated_at timestamp not null default current_timestamp,
+			primary key (id)
+		);
+		create table b (id integer primary key);
+		insert into schema_migrations (id, name, filename, up, down, status)
+		values ('0003', 'create-b', '0003_create-b_up.sql', 'create table b (id integer primary key);', 'drop table b;', 'up');
+	`)
+	assert.Ok(t, err)
+
+	fsys := fstest.MapFS{
+		"0002_create-a_up.sql":   {Data: []byte(`create table a (id integer primary key);`)},
+		"0002_create-a_down.sql": {Data: []byte(`drop table a;`)},
+		"0003_create-b_up.sql":   {Data: []byte(`create table b (id integer primary key);`)},
+		"0003_create-b_down.sql": {Data: []byte(`drop table b;`)},
+	}
+
+	m, err := NewMigratorFS(sdb, SQLite, fsys, ".", WithOutOfOrder(OutOfOrderAllow))
+	assert.Ok(t, err)
+
+	err = m.Migrate()
+	assert.Ok(t, err)
+
+	var outOfOrder bool
+	err = sdb.QueryRow("select out_of_order from schema_migrations where id = '0002'").Scan(&outOfOrder)
+	assert.Ok(t, err)
+	assert.Cond(t, outOfOrder, "expected 0002 to be recorded out of order")
+
+	ms, err := m.Migrations()
+	assert.Ok(t, err)
+	assert.Equals(t, 2, len(ms))
+	assert.Cond(t, !ms[0].OutOfOrder, "expected 0003 to be recorded in order")
+	assert.Cond(t, ms[1].OutOfOrder, "expected 0002 to be recorded out of order")
+}
+
+func TestSQLiteRecordedDetails(t *testing.T) {
+	sdb := openSQLite(t)
+
+	fsys := fstest.MapFS{
+		"0001_create-a_up.sql":   {Data: []byte(`create table a (id integer primary key);`)},
+		"0001_create-a_down.sql": {Data: []byte(`drop table a;`)},
+		"0002_create-b_up.sql":   {Data: []byte(`create table b (id integer primary key);`)},
+		"0002_create-b_down.sql": {Data: []byte(`drop table b;`)},
+	}
+
+	m, err := NewMigratorFS(sdb, SQLite, fsys, ".", WithAppVersion("v1.2.3"))
+	assert.Ok(t, err)
+
+	err = m.Migrate()
+	assert.Ok(t, err)
+
+	err = m.Redo()
+	assert.Ok(t, err)
+
+	hostname, err := os.Hostname()
+	assert.Ok(t, err)
+
+	ms, err := m.Migrations()
+	assert.Ok(t, err)
+	assert.Equals(t, 2, len(ms))
+	assert.Equals(t, int64(3), ms[0].Sequence)
+	assert.Equals(t, int64(1), ms[1].Sequence)
+	for _, m := range ms {
+		assert.Equals(t, "v1.2.3", m.AppVersion)
+		assert.Equals(t, hostname, m.Hostname)
+		assert.Equals(t, "", m.DBUser)
+	}
+
+	history, err := m.History()
+	assert.Ok(t, err)
+
+	var transitions []string
+	for _, h := range history {
+		transitions = append(transitions, string(h.Direction)+" "+h.ID)
+		assert.Equals(t, "v1.2.3", h.AppVersion)
+		assert.Equals(t, hostname, h.Hostname)
+		assert.Cond(t, !h.CreatedAt.IsZero(), "expected created_at to be set")
+	}
+	assert.Equals(t, []string{"up 0001", "up 0002", "down 0002", "up 0002"}, transitions)
+
+	history, err = m.History("0001")
+	assert.Ok(t, err)
+	assert.Equals(t, 1, len(history))
+	assert.Equals(t, "0001_create-a_up.sql", history[0].Filename)
+}